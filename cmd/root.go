@@ -4,16 +4,27 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"github.com/TZGyn/kode/internal/budget"
+	"github.com/TZGyn/kode/internal/chat"
+	"github.com/TZGyn/kode/internal/compact"
 	"github.com/TZGyn/kode/internal/config"
 	"github.com/TZGyn/kode/internal/message"
 	"github.com/TZGyn/kode/internal/model"
 	"github.com/TZGyn/kode/internal/models"
-
+	"github.com/TZGyn/kode/internal/permission"
+	"github.com/TZGyn/kode/internal/provider"
+	_ "github.com/TZGyn/kode/internal/provider/anthropic"
+	_ "github.com/TZGyn/kode/internal/provider/google"
+	_ "github.com/TZGyn/kode/internal/provider/mock"
+	_ "github.com/TZGyn/kode/internal/provider/ollama"
+	_ "github.com/TZGyn/kode/internal/provider/openai"
+	"github.com/TZGyn/kode/internal/session"
+	"github.com/TZGyn/kode/internal/workspace"
+
+	"context"
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
@@ -28,26 +39,40 @@ var rootCmd = &cobra.Command{
 	SilenceUsage:  true,
 	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		checkGitCmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
-		stdout, err := checkGitCmd.Output()
-
+		c, err := config.New()
 		if err != nil {
-			return errors.New("invalid git repo")
+			return nil
 		}
-
-		if strings.Split(string(stdout), "\n")[0] != "true" {
-			return errors.New("invalid git repo")
+		if err := provider.Configure(c); err != nil {
+			return err
 		}
 
-		c, err := config.New()
+		ws, err := workspace.Detect(c.ReadableDirectories...)
 		if err != nil {
-			return nil
+			return err
 		}
 
 		opts := []tea.ProgramOption{}
 		opts = append(opts, tea.WithOutput(os.Stderr))
 
 		messages := model.ChatMessages{}
+		permissions := permission.NewSession(c.Permissions)
+
+		current, err := resumeSession(cmd, ws)
+		if err != nil {
+			return err
+		}
+		if current != nil {
+			messages = current.Messages
+			if _, ok := models.Find(current.Provider, current.Model); ok {
+				c.DEFAULT_PROVIDER = current.Provider
+				c.DEFAULT_MODEL = current.Model
+			}
+			fmt.Println(message.SecondaryStyle.Render(fmt.Sprintf("Resumed session %s: %s (%d messages)", current.ID, current.Title, len(messages))))
+		} else {
+			current = session.New(ws.Root, c.DEFAULT_PROVIDER, c.DEFAULT_MODEL)
+		}
+		tracker := budget.NewTracker(c.Budget, ws.Root, current.Usage.Cost)
 
 		providerOpts := make([]huh.Option[models.ModelProvider], 0, len(models.Models))
 		modelOpts := map[models.ModelProvider][]huh.Option[models.ModelID]{}
@@ -93,7 +118,7 @@ var rootCmd = &cobra.Command{
 			promptForm := huh.NewForm(
 				huh.NewGroup(
 					huh.NewText().Title("Enter a prompt:").
-						Value(&prompt).Description("/model to update model"),
+						Value(&prompt).Description("/model to update model, /compact to summarize older turns, /pin to keep the last turn verbatim"),
 				),
 			)
 
@@ -134,20 +159,71 @@ var rootCmd = &cobra.Command{
 				continue
 			}
 
+			if prompt == "/pin" {
+				if pinLastTurn(messages) {
+					fmt.Println(message.SecondaryStyle.Render("Pinned the last turn, compaction keeps it verbatim"))
+					if err := current.Save(); err != nil {
+						fmt.Println(err)
+					}
+				} else {
+					fmt.Println(message.SecondaryStyle.Render("No turn to pin"))
+				}
+				continue
+			}
+
 			out, err := glamour.Render(prompt, "auto")
 			if err != nil {
 				fmt.Println(err)
 				break
 			}
 
+			client, err := provider.NewChain(c, c.DEFAULT_PROVIDER, c.DEFAULT_MODEL)
+			if err != nil {
+				return err
+			}
+			compactor, err := newCompactor(c, client, c.DEFAULT_PROVIDER, c.DEFAULT_MODEL)
+			if err != nil {
+				return err
+			}
+
+			if prompt == "/compact" {
+				usage := provider.Usage{}
+				ctx, emit := tracker.Attach(context.Background(), func(event provider.Event) {
+					if event, ok := event.(provider.UsageEvent); ok {
+						usage.Add(event.Usage)
+					}
+				})
+
+				before, after, err := compactor.Compact(ctx, &messages, emit)
+				if errors.Is(err, compact.ErrNothingToCompact) {
+					fmt.Println(message.SecondaryStyle.Render("Nothing to compact"))
+					continue
+				} else if err != nil {
+					fmt.Println(err)
+					continue
+				}
+
+				fmt.Println(message.SecondaryStyle.Render(fmt.Sprintf(
+					"Compacted %d → %d tokens (%d reclaimed) · %s", before, after, before-after, usage.String(),
+				)))
+				providerName, modelID := client.Active()
+				current.Update(prompt, messages, providerName, modelID, usage)
+				if err := current.Save(); err != nil {
+					fmt.Println(err)
+				}
+				continue
+			}
+
 			fmt.Println(message.UserStyle.Render(out))
 
-			chatModel := model.InitialModel(prompt, messages, model.ChatConfig{
-				Provider:          string(c.DEFAULT_PROVIDER),
-				Model:             string(c.DEFAULT_MODEL),
-				GEMINI_API_KEY:    c.GEMINI_API_KEY,
-				OPENAI_API_KEY:    c.OPENAI_API_KEY,
-				ANTHROPIC_API_KEY: c.ANTHROPIC_API_KEY,
+			chatModel := chat.InitialModel(prompt, messages, client, chat.ChatConfig{
+				Provider:    string(c.DEFAULT_PROVIDER),
+				Model:       string(c.DEFAULT_MODEL),
+				Permissions: permissions,
+				Workspace:   ws,
+				Budget:      tracker,
+				MaxSteps:    c.MaxSteps,
+				Compactor:   compactor,
 			})
 
 			p := tea.NewProgram(chatModel, opts...)
@@ -157,51 +233,95 @@ var rootCmd = &cobra.Command{
 				break
 			}
 
-			chatModel = m.(*model.ChatModel)
+			chatModel = m.(*chat.ChatModel)
+			chatModel.Wait()
 
 			if chatModel.Response != "" {
 				out, err := glamour.Render(chatModel.Response, "auto")
 				if err != nil {
 					fmt.Println(err)
 				}
-				fmt.Println(message.AssistantStyle.Render(out + "\n\n" + "  " + message.SecondaryStyle.Render(chatModel.Provider+" "+chatModel.Model)))
+				footer := chatModel.Provider + " " + chatModel.Model + " · " + chatModel.Usage.String() +
+					" · session " + provider.FormatCost(current.Usage.Cost+chatModel.Usage.Cost)
+				fmt.Println(message.AssistantStyle.Render(out + "\n\n" + "  " + message.SecondaryStyle.Render(footer)))
 			} else {
 				fmt.Println("No Response")
 			}
 
-			if len(chatModel.GoogleClient.Messages) > 0 {
-				messages = model.ChatMessages{}
-				err = messages.AddGoogleMessages(chatModel.GoogleClient.Messages)
-				if err != nil {
-					fmt.Println("Failed to remember google response")
-				}
-			}
-			if len(chatModel.OpenAIClient.Messages) > 0 {
-				messages = model.ChatMessages{}
-				err = messages.AddOpenAIMessages(chatModel.OpenAIClient.Messages)
-				if err != nil {
-					fmt.Println("Failed to remember openai response")
-				}
+			if chatModel.Err() != nil {
+				fmt.Println(chatModel.Err())
 			}
-			if len(chatModel.AnthropicClient.Messages) > 0 {
-				messages = model.ChatMessages{}
-				err = messages.AddAnthropicMessages(chatModel.AnthropicClient.Messages)
-				if err != nil {
-					fmt.Println("Failed to remember openai response")
-				}
 
+			messages = chatModel.Messages
+
+			if len(messages) > 0 {
+				// The turn is recorded against the model that answered it.
+				providerName, modelID := client.Active()
+				current.Update(prompt, messages, providerName, modelID, chatModel.Usage)
+				if err := current.Save(); err != nil {
+					fmt.Println(err)
+				}
 			}
 		}
 		return nil
 	},
 }
 
+// resumeSession loads the session asked for by --resume or --continue, nil
+// means a new session.
+func resumeSession(cmd *cobra.Command, ws *workspace.Workspace) (*session.Session, error) {
+	resume, _ := cmd.Flags().GetString("resume")
+	if resume != "" {
+		return session.Load(resume)
+	}
+
+	if continueLatest, _ := cmd.Flags().GetBool("continue"); continueLatest {
+		s, err := session.Latest(ws.Root)
+		if errors.Is(err, session.ErrNotFound) {
+			return nil, errors.New("no session to continue in " + ws.Root)
+		}
+		return s, err
+	}
+
+	return nil, nil
+}
+
+// pinLastTurn marks the messages of the last turn as pinned.
+func pinLastTurn(messages model.ChatMessages) bool {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			for _, m := range messages[i:] {
+				m.Pinned = true
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// newCompactor returns the compactor for the model of client, summarizing
+// with the model of the compaction config when one is set.
+func newCompactor(c *config.Config, client provider.Provider, name models.ModelProvider, id models.ModelID) (*compact.Compactor, error) {
+	info, _ := models.Find(name, id)
+
+	summarizer := client
+	if c.Compaction.Provider != "" && c.Compaction.Model != "" {
+		var err error
+		summarizer, err = provider.NewChain(c, c.Compaction.Provider, c.Compaction.Model)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return compact.New(summarizer, info, c.Compaction), nil
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
-		fmt.Println(err.Error())
+		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
 }
@@ -215,5 +335,7 @@ func init() {
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	rootCmd.Flags().BoolP("continue", "c", false, "Continue the most recent session of this workspace")
+	rootCmd.Flags().StringP("resume", "r", "", "Resume the session with the given id")
+	rootCmd.MarkFlagsMutuallyExclusive("continue", "resume")
 }
@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/TZGyn/kode/internal/agent"
+	"github.com/TZGyn/kode/internal/budget"
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/permission"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/TZGyn/kode/internal/stream"
+	"github.com/TZGyn/kode/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run [prompt]",
+	Short: "Run a prompt without the interactive interface",
+	Long: `Run a prompt without the interactive interface and print the final answer
+to stdout. The prompt is read from the arguments, from stdin, or both, in
+which case stdin is appended to the prompt:
+
+  git diff | kode run "review this change"
+
+Tool calls needing approval are rejected unless allowed with --allow or by
+the permissions of the config. kode exits non-zero when the model cannot be
+reached, when a tool call is rejected, or when one fails, such as a command
+exiting non-zero or a patch that does not apply, even if the model recovered
+from it. Failed tool calls are tolerated with --ignore-tool-errors.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := config.New()
+		if err != nil {
+			return err
+		}
+		if err := provider.Configure(c); err != nil {
+			return err
+		}
+
+		prompt, err := runPrompt(args, os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		name, _ := cmd.Flags().GetString("provider")
+		id, _ := cmd.Flags().GetString("model")
+		maxSteps, _ := cmd.Flags().GetInt("max-steps")
+		allow, _ := cmd.Flags().GetStringArray("allow")
+		deny, _ := cmd.Flags().GetStringArray("deny")
+		format, _ := cmd.Flags().GetString("output-format")
+		attach, _ := cmd.Flags().GetStringArray("attach")
+		ignoreToolErrors, _ := cmd.Flags().GetBool("ignore-tool-errors")
+
+		if format != "text" && format != "stream-json" {
+			return fmt.Errorf("unknown output format %q, expected text or stream-json", format)
+		}
+
+		providerName, modelID := c.DEFAULT_PROVIDER, c.DEFAULT_MODEL
+		if name != "" {
+			providerName = models.ModelProvider(name)
+		}
+		if id != "" {
+			modelID = models.ModelID(id)
+		}
+		if providerName == "" || modelID == "" {
+			return errors.New("no model configured, pass --provider and --model")
+		}
+
+		policy := c.Permissions
+		for _, rule := range allow {
+			r, err := permission.ParseRule(rule)
+			if err != nil {
+				return err
+			}
+			policy.Allow = append(policy.Allow, r)
+		}
+		for _, rule := range deny {
+			r, err := permission.ParseRule(rule)
+			if err != nil {
+				return err
+			}
+			policy.Deny = append(policy.Deny, r)
+		}
+
+		// Outside of a git repository the current directory is the workspace.
+		ws, err := workspace.Detect(c.ReadableDirectories...)
+		if err != nil {
+			ws, err = workspace.New(".", c.ReadableDirectories...)
+			if err != nil {
+				return err
+			}
+		}
+
+		client, err := provider.NewChain(c, providerName, modelID)
+		if err != nil {
+			return err
+		}
+
+		if !cmd.Flags().Changed("max-steps") {
+			maxSteps = c.MaxSteps
+		}
+		compactor, err := newCompactor(c, client, providerName, modelID)
+		if err != nil {
+			return err
+		}
+		runner := agent.New(client, agent.Options{MaxSteps: maxSteps, Compactor: compactor})
+
+		rejected := []string{}
+		ask := func(ctx context.Context, request permission.Request) permission.Response {
+			rejected = append(rejected, request.Tool)
+			return permission.Response{
+				Decision: permission.Reject,
+				Feedback: request.Tool + " is not allowed in non-interactive mode",
+			}
+		}
+
+		failed := []string{}
+		recordFailure := func(event provider.Event) {
+			if event, ok := event.(provider.ToolCallEndEvent); ok && event.Err != nil && !ignoreToolErrors {
+				failed = append(failed, event.Name)
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		ctx = permission.NewContext(ctx, permission.NewSession(policy), ask)
+		ctx = workspace.NewContext(ctx, ws)
+
+		tracker := budget.NewTracker(c.Budget, ws.Root, 0)
+
+		messages := model.ChatMessages{
+			{
+				Role:  "user",
+				Parts: []*model.ChatPart{{Type: "text", Text: prompt}},
+			},
+		}
+		if len(attach) > 0 && !client.Capabilities().Attachments {
+			return fmt.Errorf("%s %s does not support attachments", providerName, modelID)
+		}
+		for _, path := range attach {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			messages[0].Parts = append(messages[0].Parts, model.NewFile(path, data))
+		}
+
+		if format == "stream-json" {
+			encoder := stream.NewEncoder(os.Stdout)
+			encoder.User(messages[0])
+
+			ctx, emit := tracker.Attach(ctx, func(event provider.Event) {
+				recordFailure(event)
+				encoder.Emit(event)
+			})
+			err = runner.Run(ctx, &messages, emit)
+			if err != nil {
+				encoder.Error(err)
+			} else {
+				err = toolsError(rejected, failed)
+			}
+			encoder.Result(finalAnswer(messages), client.Usage(), err)
+			return err
+		}
+
+		ctx, emit := tracker.Attach(ctx, func(event provider.Event) {
+			recordFailure(event)
+			switch event := event.(type) {
+			case provider.ToolCallEndEvent:
+				fmt.Fprintf(os.Stderr, "> %s %s\n", event.Name, toolCallSummary(event.Args))
+				if event.Err != nil {
+					fmt.Fprintln(os.Stderr, "  failed: "+firstLine(event.Err.Error()))
+				}
+			case provider.WarningEvent:
+				fmt.Fprintln(os.Stderr, "warning: "+event.Message)
+			case provider.FallbackEvent:
+				fmt.Fprintf(os.Stderr, "falling back to %s %s: %s\n", event.Provider, event.Model, event.Err)
+			case provider.CompactionEvent:
+				fmt.Fprintf(os.Stderr, "compacted history %d → %d tokens\n", event.Before, event.After)
+			}
+		})
+		err = runner.Run(ctx, &messages, emit)
+		if err != nil && !errors.Is(err, agent.ErrStopped) {
+			return err
+		}
+
+		fmt.Println(finalAnswer(messages))
+
+		if err != nil {
+			return err
+		}
+		return toolsError(rejected, failed)
+	},
+}
+
+// toolsError reports the tool calls that were rejected or failed, nil when
+// there are none.
+func toolsError(rejected []string, failed []string) error {
+	errs := []error{}
+	if len(rejected) > 0 {
+		errs = append(errs, fmt.Errorf("tool calls were rejected: %s", strings.Join(rejected, ", ")))
+	}
+	if len(failed) > 0 {
+		errs = append(errs, fmt.Errorf("tool calls failed: %s", strings.Join(failed, ", ")))
+	}
+	return errors.Join(errs...)
+}
+
+// runPrompt joins the prompt arguments with stdin when it is not a terminal.
+func runPrompt(args []string, stdin *os.File) (string, error) {
+	prompt := strings.Join(args, " ")
+
+	if stat, err := stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice == 0 {
+		input, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(string(input)) != "" {
+			prompt = strings.TrimSpace(prompt + "\n\n" + string(input))
+		}
+	}
+
+	if strings.TrimSpace(prompt) == "" {
+		return "", errors.New("empty prompt, pass it as an argument or on stdin")
+	}
+	return prompt, nil
+}
+
+// finalAnswer returns the text of the last assistant message.
+func finalAnswer(messages model.ChatMessages) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			continue
+		}
+
+		text := []string{}
+		for _, part := range messages[i].Parts {
+			if part.Type == "text" && part.Text != "" {
+				text = append(text, part.Text)
+			}
+		}
+		if len(text) > 0 {
+			return strings.Join(text, "\n")
+		}
+	}
+	return ""
+}
+
+func toolCallSummary(args map[string]any) string {
+	for _, key := range []string{"command", "path", "filePath", "directory"} {
+		if value, ok := args[key].(string); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+
+	runCmd.Flags().String("provider", "", "Provider to use, defaults to the configured one")
+	runCmd.Flags().String("model", "", "Model to use, defaults to the configured one")
+	runCmd.Flags().Int("max-steps", agent.DefaultMaxSteps, "Maximum number of model requests before the model is asked to wrap up, defaults to max_steps of the config")
+	runCmd.Flags().StringArray("allow", nil, `Allow tool calls without approval, as "tool" or "tool:path glob"`)
+	runCmd.Flags().StringArray("deny", nil, `Deny tool calls, as "tool" or "tool:path glob"`)
+	runCmd.Flags().StringArray("attach", nil, "Attach a file, an image, a PDF or a text file, to the prompt")
+	runCmd.Flags().Bool("ignore-tool-errors", false, "Exit zero when tool calls fail, as long as the model answers")
+	runCmd.Flags().String("output-format", "text", "Output format, text or stream-json for one JSON event per line")
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/TZGyn/kode/internal/message"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/TZGyn/kode/internal/session"
+	"github.com/charmbracelet/glamour"
+	"github.com/spf13/cobra"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage saved sessions",
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved sessions, most recent first",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sessions, err := session.List()
+		if err != nil {
+			return err
+		}
+
+		if len(sessions) == 0 {
+			fmt.Println("No sessions")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tUPDATED\tMODEL\tMESSAGES\tCOST\tDIRECTORY\tTITLE")
+		for _, s := range sessions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n", s.ID, s.UpdatedAt.Format(time.DateTime), s.Model, s.MessageCount(), provider.FormatCost(s.Usage.Cost), s.Directory, s.Title)
+		}
+		return w.Flush()
+	},
+}
+
+var sessionsShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Print the conversation of a session",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := session.Load(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(message.SecondaryStyle.Render(fmt.Sprintf(
+			"%s  %s %s  %s\ncreated %s  updated %s  %d turns  %s",
+			s.ID, s.Provider, s.Model, s.Directory,
+			s.CreatedAt.Format(time.DateTime), s.UpdatedAt.Format(time.DateTime),
+			len(s.Turns), s.Usage,
+		)))
+
+		for _, m := range s.Messages {
+			for _, part := range m.Parts {
+				switch part.Type {
+				case "text":
+					if part.Text == "" {
+						continue
+					}
+					out, err := glamour.Render(part.Text, "auto")
+					if err != nil {
+						out = part.Text
+					}
+					if m.Role == "user" {
+						fmt.Println(message.UserStyle.Render(out))
+					} else {
+						fmt.Println(message.AssistantStyle.Render(out))
+					}
+				case "file":
+					fmt.Println(message.SecondaryStyle.Render("  attached " + part.Filename + " (" + part.MediaType + ")"))
+				case "tool-call":
+					fmt.Println(message.SecondaryStyle.Render("  " + part.ToolCallName + " " + fmt.Sprint(part.ToolCallArgs)))
+				}
+			}
+			if m.Model != "" {
+				fmt.Println(message.SecondaryStyle.Render("  " + string(m.Provider) + " " + string(m.Model)))
+			}
+		}
+
+		return nil
+	},
+}
+
+var sessionsRmCmd = &cobra.Command{
+	Use:   "rm <id>...",
+	Short: "Delete saved sessions",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, id := range args {
+			s, err := session.Remove(id)
+			if err != nil {
+				return err
+			}
+			fmt.Println("Removed " + s.ID)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sessionsCmd)
+	sessionsCmd.AddCommand(sessionsListCmd, sessionsShowCmd, sessionsRmCmd)
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/TZGyn/kode/internal/tokens"
+	"github.com/spf13/cobra"
+)
+
+var tokensCmd = &cobra.Command{
+	Use:   "tokens <file>",
+	Short: "Count the tokens of a file",
+	Long: `Count the tokens of a file, or of stdin when the file is -, for every model
+family, or for a single model with --provider and --model.
+
+Counting works offline. OpenAI models are counted exactly with the tiktoken
+vocabularies shipped with kode, cl100k_base.tiktoken and o200k_base.tiktoken
+files in the tokenizers directory taking precedence. Other counts are
+estimates.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var data []byte
+		var err error
+		if args[0] == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			return err
+		}
+		text := string(data)
+
+		name, _ := cmd.Flags().GetString("provider")
+		id, _ := cmd.Flags().GetString("model")
+
+		counters := []tokens.Counter{
+			tokens.Encoding(tokens.CL100K),
+			tokens.Encoding(tokens.O200K),
+			tokens.Anthropic,
+			tokens.Gemini,
+		}
+		if name != "" || id != "" {
+			c, err := config.New()
+			if err != nil {
+				return err
+			}
+			if err := provider.Configure(c); err != nil {
+				return err
+			}
+
+			providerName, modelID := c.DEFAULT_PROVIDER, c.DEFAULT_MODEL
+			if name != "" {
+				providerName = models.ModelProvider(name)
+			}
+			if id != "" {
+				modelID = models.ModelID(id)
+			}
+
+			info, ok := models.Find(providerName, modelID)
+			if !ok {
+				return errors.New("unknown model " + string(providerName) + " " + string(modelID))
+			}
+			counters = []tokens.Counter{tokens.ForModel(info)}
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "COUNTER\tTOKENS\tEXACT")
+		for _, counter := range counters {
+			fmt.Fprintf(w, "%s\t%d\t%t\n", counter.Name(), counter.Count(text), counter.Exact())
+		}
+		fmt.Fprintf(w, "\n%d bytes, vocabularies in %s override the embedded ones\n", len(data), tokens.Dir())
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tokensCmd)
+
+	tokensCmd.Flags().String("provider", "", "Provider of the model to count for, defaults to the configured one")
+	tokensCmd.Flags().String("model", "", "Model to count for, defaults to the configured one")
+}
@@ -0,0 +1,210 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/TZGyn/kode/internal/compact"
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/TZGyn/kode/internal/tool"
+)
+
+const (
+	DefaultMaxSteps   = 50
+	DefaultMaxRepeats = 3
+)
+
+// ErrStopped is returned when a turn was cut short by a limit. The history
+// then ends with the summary the model was asked for.
+var ErrStopped = errors.New("turn stopped before completion")
+
+const summaryPrompt = `The turn has to stop because %s. Do not call any more tools.
+Summarize what has been done so far, what remains to be done, and how to continue.`
+
+type Options struct {
+	// MaxSteps is the number of model requests a turn can make before being
+	// stopped, DefaultMaxSteps when zero.
+	MaxSteps int
+	// MaxRepeats is the number of times in a row a turn can make the same
+	// tool call with the same arguments, DefaultMaxRepeats when zero. A call
+	// made again after a step without it, as when rerunning the tests after
+	// a fix, starts counting again.
+	MaxRepeats int
+	// Compactor, when set, summarizes the older turns before a request once
+	// the history nears the context window.
+	Compactor *compact.Compactor
+}
+
+// Agent runs turns against a provider, executing the tools the model calls.
+type Agent struct {
+	provider provider.Provider
+	options  Options
+}
+
+func New(p provider.Provider, options Options) *Agent {
+	if options.MaxSteps <= 0 {
+		options.MaxSteps = DefaultMaxSteps
+	}
+	if options.MaxRepeats <= 0 {
+		options.MaxRepeats = DefaultMaxRepeats
+	}
+
+	return &Agent{provider: p, options: options}
+}
+
+// Run runs a turn on messages, which ends with the user prompt. The model
+// reply is appended, the tools it calls are executed and their results sent
+// back until it answers without calling tools. When a limit is reached the
+// model is asked for a final summary instead. messages holds the turn even
+// when an error is returned.
+func (a *Agent) Run(ctx context.Context, messages *model.ChatMessages, emit provider.EmitFunc) error {
+	seen := map[string]int{}
+	tools := []string{}
+
+	for step := 1; ; step++ {
+		if err := provider.Step(ctx); err != nil {
+			return err
+		}
+		emit(provider.StepEvent{Step: step, MaxSteps: a.options.MaxSteps, Tools: tools})
+
+		// A failed compaction leaves the history as it was, the request may
+		// still fit.
+		if a.options.Compactor != nil {
+			if err := a.options.Compactor.Maybe(ctx, messages, emit); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				emit(provider.WarningEvent{Message: "compaction failed: " + err.Error()})
+			}
+		}
+
+		reply, err := a.provider.Stream(ctx, provider.Request{Messages: *messages}, emit)
+		if err != nil {
+			return err
+		}
+		*messages = append(*messages, reply)
+
+		calls := toolCalls(reply)
+		if len(calls) == 0 {
+			return nil
+		}
+
+		// Every call gets a result, even the ones not executed, so the
+		// history stays valid for every provider.
+		stop := ""
+		tools = []string{}
+		repeats := map[string]int{}
+		results := &model.ChatMessage{Role: "tool"}
+		for _, call := range calls {
+			tools = append(tools, call.ToolCallName)
+
+			key := signature(call)
+			if _, ok := repeats[key]; !ok {
+				repeats[key] = seen[key]
+			}
+			repeats[key]++
+			if repeats[key] > a.options.MaxRepeats {
+				stop = fmt.Sprintf("%s was called %d times in a row with the same arguments", call.ToolCallName, repeats[key])
+				results.Parts = append(results.Parts, model.NewToolResult(
+					call.ToolCallID, call.ToolCallName, "Not executed, this exact call was already made",
+				))
+				continue
+			}
+
+			results.Parts = append(results.Parts, a.execute(ctx, call, emit))
+		}
+		*messages = append(*messages, results)
+		seen = repeats
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if stop == "" && step >= a.options.MaxSteps {
+			stop = fmt.Sprintf("the limit of %d steps was reached", a.options.MaxSteps)
+		}
+		if stop != "" {
+			if err := a.summarize(ctx, messages, stop, emit); err != nil {
+				return err
+			}
+			return fmt.Errorf("%w: %s", ErrStopped, stop)
+		}
+	}
+}
+
+func (a *Agent) execute(ctx context.Context, call *model.ChatPart, emit provider.EmitFunc) *model.ChatPart {
+	emit(provider.ToolCallEvent{ID: call.ToolCallID, Name: call.ToolCallName, Args: call.ToolCallArgs})
+
+	// The result of a failed call describes the failure to the model, the
+	// error itself when the tool gave no result.
+	output := ""
+	result := call.ArgsError()
+	var err error
+	if result != "" {
+		err = errors.New(result)
+	} else {
+		result, err = tool.HandleTool(ctx, call.ToolCallName, call.ToolCallArgs, &output)
+		if err != nil && result == "" {
+			result = err.Error()
+		}
+	}
+
+	emit(provider.ToolCallEndEvent{
+		ID:     call.ToolCallID,
+		Name:   call.ToolCallName,
+		Args:   call.ToolCallArgs,
+		Result: result,
+		Output: output,
+		Err:    err,
+	})
+
+	return model.NewToolResult(call.ToolCallID, call.ToolCallName, result)
+}
+
+// summarize asks the model to wrap up the turn without calling tools.
+func (a *Agent) summarize(ctx context.Context, messages *model.ChatMessages, reason string, emit provider.EmitFunc) error {
+	emit(provider.WarningEvent{Message: "stopping, " + reason})
+
+	if err := provider.Step(ctx); err != nil {
+		return err
+	}
+
+	*messages = append(*messages, &model.ChatMessage{
+		Role:  "user",
+		Parts: []*model.ChatPart{{Type: "text", Text: fmt.Sprintf(summaryPrompt, reason)}},
+	})
+
+	reply, err := a.provider.Stream(ctx, provider.Request{Messages: *messages, NoTools: true}, emit)
+	if err != nil {
+		return err
+	}
+
+	// Calls made anyway would never get a result.
+	reply.Parts = slices.DeleteFunc(reply.Parts, func(part *model.ChatPart) bool {
+		return part.Type == "tool-call"
+	})
+	*messages = append(*messages, reply)
+
+	return nil
+}
+
+func toolCalls(message *model.ChatMessage) []*model.ChatPart {
+	calls := []*model.ChatPart{}
+	for _, part := range message.Parts {
+		if part.Type == "tool-call" {
+			calls = append(calls, part)
+		}
+	}
+	return calls
+}
+
+// signature identifies a call by its tool and arguments, json.Marshal sorts
+// the keys so equal arguments give equal signatures.
+func signature(call *model.ChatPart) string {
+	args, _ := json.Marshal(call.ToolCallArgs)
+	return call.ToolCallName + " " + string(args)
+}
@@ -0,0 +1,190 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/TZGyn/kode/internal/provider/mock"
+	"github.com/TZGyn/kode/internal/workspace"
+)
+
+// run runs a turn of the scripted responses in a workspace holding a.txt and
+// b.txt, returning the history.
+func run(t *testing.T, options Options, responses ...mock.Response) (model.ChatMessages, error) {
+	t.Helper()
+	return runEmit(t, options, func(provider.Event) {}, responses...)
+}
+
+// runEmit is run with the events of the turn sent to emit.
+func runEmit(t *testing.T, options Options, emit provider.EmitFunc, responses ...mock.Response) (model.ChatMessages, error) {
+	t.Helper()
+
+	root := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("content of "+name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws, err := workspace.New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := mock.FromScript(&mock.Script{Responses: responses})
+	messages := model.ChatMessages{{Role: "user", Parts: []*model.ChatPart{{Type: "text", Text: "go"}}}}
+	err = New(client, options).Run(workspace.NewContext(context.Background(), ws), &messages, emit)
+	return messages, err
+}
+
+func cat(name string) mock.Response {
+	return mock.Response{ToolCalls: []mock.ToolCall{{Name: "cat_file", Args: map[string]any{"filePath": name}}}}
+}
+
+func TestRunRepeatsAfterOtherSteps(t *testing.T) {
+	// Rerunning a call between other steps, as the tests between fixes.
+	responses := []mock.Response{}
+	for range 4 {
+		responses = append(responses, cat("a.txt"), cat("b.txt"))
+	}
+	responses = append(responses, mock.Response{Text: "done"})
+
+	if _, err := run(t, Options{}, responses...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunStopsConsecutiveRepeats(t *testing.T) {
+	messages, err := run(t, Options{},
+		cat("a.txt"), cat("a.txt"), cat("a.txt"), cat("a.txt"),
+		mock.Response{Text: "summary"},
+	)
+	if !errors.Is(err, ErrStopped) {
+		t.Fatalf("err = %v, want ErrStopped", err)
+	}
+
+	results := messages[len(messages)-3]
+	if results.Role != "tool" || results.Parts[0].ResultText() != "Not executed, this exact call was already made" {
+		t.Fatalf("the repeated call was executed")
+	}
+	if last := messages[len(messages)-1]; last.Parts[0].Text != "summary" {
+		t.Errorf("last message = %q, want the summary", last.Parts[0].Text)
+	}
+}
+
+func TestRunToolRoundTrip(t *testing.T) {
+	messages, err := run(t, Options{},
+		mock.Response{Text: "Let me look.", ToolCalls: []mock.ToolCall{{Name: "cat_file", Args: map[string]any{"filePath": "a.txt"}}}},
+		mock.Response{Text: "It says hello."},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles := []string{}
+	for _, message := range messages {
+		roles = append(roles, message.Role)
+	}
+	if want := []string{"user", "assistant", "tool", "assistant"}; !slices.Equal(roles, want) {
+		t.Fatalf("roles = %v, want %v", roles, want)
+	}
+
+	call, result := messages[1].Parts[1], messages[2].Parts[0]
+	if result.ToolCallID != call.ToolCallID || result.ToolCallName != "cat_file" {
+		t.Errorf("result %+v does not answer %+v", result, call)
+	}
+	if !strings.Contains(result.ResultText(), "content of a.txt") {
+		t.Errorf("result = %q, want the content of a.txt", result.ResultText())
+	}
+	if messages[3].Parts[0].Text != "It says hello." {
+		t.Errorf("answer = %q", messages[3].Parts[0].Text)
+	}
+}
+
+func TestRunMaxSteps(t *testing.T) {
+	messages, err := run(t, Options{MaxSteps: 2},
+		cat("a.txt"), cat("b.txt"),
+		mock.Response{Text: "Read both files, nothing left.", ToolCalls: []mock.ToolCall{{Name: "cat_file", Args: map[string]any{"filePath": "a.txt"}}}},
+	)
+	if !errors.Is(err, ErrStopped) {
+		t.Fatalf("err = %v, want ErrStopped", err)
+	}
+
+	prompt := messages[len(messages)-2]
+	if prompt.Role != "user" || !strings.Contains(prompt.Parts[0].Text, "the limit of 2 steps was reached") {
+		t.Errorf("summary prompt = %+v", prompt.Parts[0])
+	}
+	summary := messages[len(messages)-1]
+	if len(summary.Parts) != 1 || summary.Parts[0].Text != "Read both files, nothing left." {
+		t.Errorf("summary = %+v, want its text alone", summary.Parts)
+	}
+}
+
+func TestRunScriptedErrors(t *testing.T) {
+	_, err := run(t, Options{}, mock.Response{Error: "overloaded", Status: 529, RetryAfter: mock.Duration(2 * time.Second)})
+
+	statusErr := (*provider.StatusError)(nil)
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("err = %v, want a status error", err)
+	}
+	if statusErr.StatusCode != 529 || statusErr.RetryAfter != 2*time.Second || !provider.Retryable(err) {
+		t.Errorf("status error = %+v, want a retryable 529", statusErr)
+	}
+
+	_, err = run(t, Options{}, mock.Response{Error: "invalid request"})
+	if err == nil || provider.Retryable(err) {
+		t.Errorf("err = %v, want an error that is not retried", err)
+	}
+}
+
+func TestRunMalformedArguments(t *testing.T) {
+	call := model.NewToolCall("call_1", "cat_file", `{"filePath": "a.txt"`)
+
+	result := New(nil, Options{}).execute(context.Background(), call, func(provider.Event) {})
+	if !strings.HasPrefix(result.ResultText(), "Not executed, the arguments are not a valid JSON object") {
+		t.Errorf("result = %q", result.ResultText())
+	}
+}
+
+// TestRunToolErrors reports the failed calls, their result describing the
+// failure to the model.
+func TestRunToolErrors(t *testing.T) {
+	errs := map[string]error{}
+	messages, err := runEmit(t, Options{}, func(event provider.Event) {
+		if event, ok := event.(provider.ToolCallEndEvent); ok {
+			errs[event.ID] = event.Err
+		}
+	},
+		mock.Response{ToolCalls: []mock.ToolCall{
+			{Name: "cat_file", Args: map[string]any{"filePath": "a.txt"}},
+			{Name: "cat_file", Args: map[string]any{"filePath": "missing.txt"}},
+			{Name: "unknown_tool", Args: map[string]any{}},
+		}},
+		mock.Response{Text: "done"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := messages[2].Parts
+	if len(errs) != 3 || errs[results[0].ToolCallID] != nil {
+		t.Fatalf("errors = %v, want the first call to succeed", errs)
+	}
+	for _, result := range results[1:] {
+		callErr := errs[result.ToolCallID]
+		if callErr == nil {
+			t.Errorf("%s succeeded", result.ToolCallName)
+			continue
+		}
+		if !strings.Contains(result.ResultText(), callErr.Error()) {
+			t.Errorf("result of %s = %q, want the error %q", result.ToolCallName, result.ResultText(), callErr)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package budget
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/adrg/xdg"
+)
+
+var ErrExceeded = errors.New("budget exceeded")
+
+const (
+	defaultWarn = 0.8
+	keepDays    = 90
+)
+
+// Tracker accumulates the cost of a session and refuses model requests once a
+// limit is reached. Daily and project totals are kept in a ledger shared by
+// every kode process.
+type Tracker struct {
+	limits  config.Budget
+	project string
+
+	mu      sync.Mutex
+	session float64
+	warned  map[string]bool
+}
+
+// NewTracker returns a tracker for the session started in the project
+// directory, spent being what the session already cost when it is resumed.
+func NewTracker(limits config.Budget, project string, spent float64) *Tracker {
+	if limits.Warn <= 0 || limits.Warn > 1 {
+		limits.Warn = defaultWarn
+	}
+
+	return &Tracker{
+		limits:  limits,
+		project: project,
+		session: spent,
+		warned:  map[string]bool{},
+	}
+}
+
+// Attach wires the tracker to a turn: the returned context makes providers
+// check the limits before each request, and the returned emit records usage
+// before forwarding events to emit.
+func (t *Tracker) Attach(ctx context.Context, emit provider.EmitFunc) (context.Context, provider.EmitFunc) {
+	ctx = provider.WithCheck(ctx, func(ctx context.Context) error {
+		warnings, err := t.Check()
+		for _, warning := range warnings {
+			emit(provider.WarningEvent{Message: warning})
+		}
+		return err
+	})
+
+	return ctx, func(event provider.Event) {
+		if usage, ok := event.(provider.UsageEvent); ok {
+			if err := t.Record(usage.Usage); err != nil {
+				emit(provider.WarningEvent{Message: "cannot record spending: " + err.Error()})
+			}
+		}
+		emit(event)
+	}
+}
+
+// Check returns an error wrapping ErrExceeded when a limit is reached, and a
+// warning the first time each soft threshold is crossed.
+func (t *Tracker) Check() ([]string, error) {
+	l, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	scopes := []struct {
+		name  string
+		limit float64
+		spent float64
+		key   string
+	}{
+		{"session", t.limits.Session, t.session, "session"},
+		{"daily", t.limits.Day, l.Days[today()], "day"},
+		{"project", t.limits.Project, l.Projects[t.project], "project"},
+	}
+
+	warnings := []string{}
+	for _, scope := range scopes {
+		if scope.limit <= 0 {
+			continue
+		}
+		if scope.spent >= scope.limit {
+			return warnings, fmt.Errorf(
+				"%w: %s budget of %s reached with %s spent, raise budget.%s in kode.json to continue",
+				ErrExceeded, scope.name, provider.FormatCost(scope.limit), provider.FormatCost(scope.spent), scope.key,
+			)
+		}
+		if scope.spent >= scope.limit*t.limits.Warn && !t.warned[scope.name] {
+			t.warned[scope.name] = true
+			warnings = append(warnings, fmt.Sprintf(
+				"%s of the %s %s budget spent",
+				provider.FormatCost(scope.spent), provider.FormatCost(scope.limit), scope.name,
+			))
+		}
+	}
+	return warnings, nil
+}
+
+// Record adds the cost of usage to the session and to the ledger.
+func (t *Tracker) Record(usage provider.Usage) error {
+	if usage.Cost == 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	t.session += usage.Cost
+	t.mu.Unlock()
+
+	unlock, err := lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	l, err := load()
+	if err != nil {
+		return err
+	}
+	l.Days[today()] += usage.Cost
+	l.Projects[t.project] += usage.Cost
+	return l.save()
+}
+
+type ledger struct {
+	Days     map[string]float64 `json:"days"`
+	Projects map[string]float64 `json:"projects"`
+}
+
+func ledgerPath() (string, error) {
+	return xdg.DataFile(filepath.Join("kode", "spending.json"))
+}
+
+// lock takes the lock of the ledger, so that the processes recording their
+// spending at the same time do not overwrite each other, until unlock is
+// called.
+func lock() (unlock func(), err error) {
+	path, err := ledgerPath()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", file.Name(), err)
+	}
+
+	return func() {
+		unlockFile(file)
+		file.Close()
+	}, nil
+}
+
+func load() (*ledger, error) {
+	l := &ledger{Days: map[string]float64{}, Projects: map[string]float64{}}
+
+	path, err := ledgerPath()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, l); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if l.Days == nil {
+		l.Days = map[string]float64{}
+	}
+	if l.Projects == nil {
+		l.Projects = map[string]float64{}
+	}
+	return l, nil
+}
+
+// save drops the days older than keepDays and replaces the ledger atomically.
+func (l *ledger) save() error {
+	path, err := ledgerPath()
+	if err != nil {
+		return err
+	}
+
+	oldest := time.Now().AddDate(0, 0, -keepDays).Format(time.DateOnly)
+	for day := range l.Days {
+		if day < oldest {
+			delete(l.Days, day)
+		}
+	}
+
+	content, err := json.MarshalIndent(l, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "spending-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func today() string {
+	return time.Now().Format(time.DateOnly)
+}
@@ -0,0 +1,85 @@
+package budget
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/adrg/xdg"
+)
+
+func setup(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+}
+
+// TestRecordConcurrently records from trackers of their own, as separate
+// processes do, and expects no spending to be lost.
+func TestRecordConcurrently(t *testing.T) {
+	setup(t)
+
+	const trackers, records = 8, 25
+	wg := sync.WaitGroup{}
+	for range trackers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			tracker := NewTracker(config.Budget{}, "/project", 0)
+			for range records {
+				if err := tracker.Record(provider.Usage{Cost: 0.01}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	l, err := load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := trackers * records * 0.01
+	if spent := l.Projects["/project"]; math.Abs(spent-want) > 1e-9 {
+		t.Errorf("project spent %f, want %f", spent, want)
+	}
+	if spent := l.Days[today()]; math.Abs(spent-want) > 1e-9 {
+		t.Errorf("day spent %f, want %f", spent, want)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	setup(t)
+
+	tracker := NewTracker(config.Budget{Session: 1, Project: 2}, "/project", 0.5)
+
+	if warnings, err := tracker.Check(); err != nil || len(warnings) != 0 {
+		t.Fatalf("Check() = %v, %v", warnings, err)
+	}
+
+	if err := tracker.Record(provider.Usage{Cost: 0.35}); err != nil {
+		t.Fatal(err)
+	}
+	if warnings, err := tracker.Check(); err != nil || len(warnings) != 1 {
+		t.Fatalf("Check() = %v, %v, want a session warning", warnings, err)
+	}
+
+	if err := tracker.Record(provider.Usage{Cost: 0.2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tracker.Check(); !errors.Is(err, ErrExceeded) {
+		t.Errorf("Check() = %v, want ErrExceeded", err)
+	}
+
+	// The project total is shared with the next sessions.
+	next := NewTracker(config.Budget{Project: 0.5}, "/project", 0)
+	if _, err := next.Check(); !errors.Is(err, ErrExceeded) {
+		t.Errorf("Check() of a new session = %v, want ErrExceeded", err)
+	}
+}
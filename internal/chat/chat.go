@@ -0,0 +1,450 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/TZGyn/kode/internal/agent"
+	"github.com/TZGyn/kode/internal/animation"
+	"github.com/TZGyn/kode/internal/budget"
+	"github.com/TZGyn/kode/internal/compact"
+	"github.com/TZGyn/kode/internal/message"
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/permission"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/TZGyn/kode/internal/workspace"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+type state int
+
+const (
+	startState state = iota
+	requestState
+	responseState
+	doneState
+)
+
+type ChatModel struct {
+	anim   tea.Model
+	state  state
+	status string
+
+	Provider string
+	Model    string
+
+	client provider.Provider
+	err    error
+
+	context context.Context
+	cancel  context.CancelFunc
+	events  chan tea.Msg
+	started bool
+	done    chan doneMsg
+
+	permissions *permission.Session
+	workspace   *workspace.Workspace
+	budget      *budget.Tracker
+	maxSteps    int
+	compactor   *compact.Compactor
+	step        provider.StepEvent
+	warning     string
+	approval    *approvalMsg
+	feedback    textinput.Model
+
+	Messages model.ChatMessages
+	Usage    provider.Usage
+
+	Prompt   string
+	Response string
+
+	glam         *glamour.TermRenderer
+	glamHeight   int
+	glamViewport viewport.Model
+	glamOutput   string
+	width        int
+	height       int
+
+	renderer *lipgloss.Renderer
+}
+
+type ChatConfig struct {
+	Provider    string               `json:"provider"`
+	Model       string               `json:"model"`
+	Permissions *permission.Session  `json:"-"`
+	Workspace   *workspace.Workspace `json:"-"`
+	Budget      *budget.Tracker      `json:"-"`
+	MaxSteps    int                  `json:"max_steps"`
+	Compactor   *compact.Compactor   `json:"-"`
+}
+
+type initMsg struct{}
+type generatingMsg struct{}
+type doneMsg struct {
+	messages model.ChatMessages
+	err      error
+}
+
+// approvalMsg asks the user about a tool call, the answer is sent on reply.
+type approvalMsg struct {
+	request permission.Request
+	reply   chan permission.Response
+}
+
+func InitialModel(prompt string, messages model.ChatMessages, client provider.Provider, config ChatConfig) *ChatModel {
+	gr, _ := glamour.NewTermRenderer(
+		glamour.WithEnvironmentConfig(),
+		glamour.WithAutoStyle(),
+		glamour.WithWordWrap(80),
+	)
+	vp := viewport.New(0, 0)
+	vp.GotoBottom()
+
+	renderer := lipgloss.NewRenderer(os.Stderr, termenv.WithColorCache(true))
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	feedback := textinput.New()
+	feedback.Placeholder = "Tell the model what to do instead"
+
+	return &ChatModel{
+		state: startState,
+
+		Provider: config.Provider,
+		Model:    config.Model,
+
+		client: client,
+
+		context: ctx,
+		cancel:  cancel,
+		events:  make(chan tea.Msg),
+		done:    make(chan doneMsg, 1),
+
+		permissions: config.Permissions,
+		workspace:   config.Workspace,
+		budget:      config.Budget,
+		maxSteps:    config.MaxSteps,
+		compactor:   config.Compactor,
+		feedback:    feedback,
+
+		Messages: messages,
+
+		Prompt:       prompt,
+		glam:         gr,
+		glamViewport: vp,
+		renderer:     renderer,
+	}
+}
+
+func (m *ChatModel) Init() tea.Cmd {
+	// Just return `nil`, which means "no I/O right now, please."
+
+	return func() tea.Msg { return initMsg{} }
+}
+func (m *ChatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+
+	switch msg := msg.(type) {
+	case initMsg:
+		m.state = requestState
+		m.anim = animation.NewAnim("Generating")
+		cmds = append(cmds, m.anim.Init(), func() tea.Msg { return generatingMsg{} })
+	case generatingMsg:
+		messages := append(model.ChatMessages{}, m.Messages...)
+		messages = append(messages, &model.ChatMessage{
+			Role:  "user",
+			Parts: []*model.ChatPart{{Type: "text", Text: m.Prompt}},
+		})
+
+		m.started = true
+		go m.generate(messages)
+		cmds = append(cmds, m.waitForEvent)
+	case provider.TextDeltaEvent:
+		m.state = responseState
+		m.Response += msg.Text
+		m.render()
+		cmds = append(cmds, m.waitForEvent)
+	case provider.ToolCallStartEvent:
+		m.status = msg.Name
+		cmds = append(cmds, m.waitForEvent)
+	case provider.ToolCallEvent:
+		m.status = "running " + msg.Name
+		cmds = append(cmds, m.waitForEvent)
+	case provider.ToolCallEndEvent:
+		m.state = responseState
+		m.status = ""
+		m.Response += msg.Output
+		m.render()
+		cmds = append(cmds, m.waitForEvent)
+	case provider.StepEvent:
+		m.step = msg
+		cmds = append(cmds, m.waitForEvent)
+	case provider.WarningEvent:
+		m.warning = msg.Message
+		cmds = append(cmds, m.waitForEvent)
+	case provider.FallbackEvent:
+		m.Provider, m.Model = string(msg.Provider), string(msg.Model)
+		m.warning = "falling back to " + m.Model + ": " + msg.Err.Error()
+		cmds = append(cmds, m.waitForEvent)
+	case provider.CompactionEvent:
+		m.warning = fmt.Sprintf("compacted history %d → %d tokens", msg.Before, msg.After)
+		cmds = append(cmds, m.waitForEvent)
+	case provider.UsageEvent:
+		// A reply is complete, what follows starts on a line of its own.
+		if m.Response != "" && !strings.HasSuffix(m.Response, "\n") {
+			m.Response += "\n"
+		}
+		m.Usage.Add(msg.Usage)
+		cmds = append(cmds, m.waitForEvent)
+	case approvalMsg:
+		m.approval = &msg
+		m.status = "waiting for approval"
+	case doneMsg:
+		m.Messages = msg.messages
+		m.err = msg.err
+		m.state = doneState
+
+		return m, m.quit
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.glamViewport.Width = m.width
+		m.glamViewport.Height = m.height
+		return m, nil
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			return m, m.quit
+		}
+
+		if m.approval != nil {
+			return m, m.updateApproval(msg)
+		}
+	}
+
+	if m.state == requestState || m.state == responseState {
+		var cmd tea.Cmd
+		m.anim, cmd = m.anim.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+
+	if m.viewportNeeded() {
+		var cmd tea.Cmd
+		m.glamViewport, cmd = m.glamViewport.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+
+	return m, tea.Batch(cmds...)
+}
+
+// generate runs the turn in the background. The history is only handed back
+// to the model through doneMsg so that nothing is shared with the tea loop,
+// and through done as the events are no longer read once the user quit.
+func (m *ChatModel) generate(messages model.ChatMessages) {
+	ctx := permission.NewContext(m.context, m.permissions, m.ask)
+	if m.workspace != nil {
+		ctx = workspace.NewContext(ctx, m.workspace)
+	}
+	emit := m.emit
+	if m.budget != nil {
+		ctx, emit = m.budget.Attach(ctx, emit)
+	}
+
+	err := agent.New(m.client, agent.Options{MaxSteps: m.maxSteps, Compactor: m.compactor}).Run(ctx, &messages, emit)
+	done := doneMsg{messages: messages, err: err}
+	m.done <- done
+	m.emit(done)
+}
+
+// Wait waits for the turn to return once the program exited, the user may
+// have quit while it was running, and takes the history it left.
+func (m *ChatModel) Wait() {
+	if !m.started {
+		return
+	}
+	m.started = false
+
+	done := <-m.done
+	m.Messages = done.messages
+	m.err = done.err
+}
+
+// ask is called from the generating goroutine and blocks until the user
+// answers the approval prompt.
+func (m *ChatModel) ask(ctx context.Context, request permission.Request) permission.Response {
+	reply := make(chan permission.Response, 1)
+	m.emit(approvalMsg{request: request, reply: reply})
+
+	select {
+	case response := <-reply:
+		return response
+	case <-ctx.Done():
+		return permission.Response{Decision: permission.Reject}
+	}
+}
+
+func (m *ChatModel) updateApproval(msg tea.KeyMsg) tea.Cmd {
+	if m.feedback.Focused() {
+		switch msg.String() {
+		case "enter":
+			return m.answer(permission.Response{
+				Decision: permission.Reject,
+				Feedback: m.feedback.Value(),
+			})
+		case "esc":
+			m.feedback.Blur()
+			return nil
+		}
+
+		var cmd tea.Cmd
+		m.feedback, cmd = m.feedback.Update(msg)
+		return cmd
+	}
+
+	switch msg.String() {
+	case "y", "enter":
+		return m.answer(permission.Response{Decision: permission.Accept})
+	case "a":
+		return m.answer(permission.Response{Decision: permission.AcceptSession})
+	case "n", "esc":
+		return m.answer(permission.Response{Decision: permission.Reject})
+	case "f":
+		m.feedback.SetValue("")
+		return m.feedback.Focus()
+	}
+
+	return nil
+}
+
+func (m *ChatModel) answer(response permission.Response) tea.Cmd {
+	m.approval.reply <- response
+	m.approval = nil
+	m.status = ""
+	m.feedback.Blur()
+	return m.waitForEvent
+}
+
+func (m *ChatModel) emit(event provider.Event) {
+	select {
+	case m.events <- event:
+	case <-m.context.Done():
+	}
+}
+
+func (m *ChatModel) waitForEvent() tea.Msg {
+	return <-m.events
+}
+
+func (m *ChatModel) render() {
+	wasAtBottom := m.glamViewport.ScrollPercent() == 1.0
+	oldHeight := m.glamHeight
+
+	var err error
+	m.glamOutput, err = m.glam.Render(m.Response)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	m.glamOutput = strings.TrimRightFunc(m.glamOutput, unicode.IsSpace)
+	m.glamOutput = strings.ReplaceAll(m.glamOutput, "\t", strings.Repeat(" ", 4))
+
+	m.glamHeight = lipgloss.Height(m.glamOutput)
+
+	truncatedGlamOutput := m.renderer.NewStyle().
+		MaxWidth(m.width).
+		Render(m.glamOutput)
+
+	m.glamViewport.SetContent(truncatedGlamOutput)
+
+	if oldHeight < m.glamHeight && wasAtBottom {
+		// If the viewport's at the bottom and we've received a new
+		// line of content, follow the output by auto scrolling to
+		// the bottom.
+		m.glamViewport.GotoBottom()
+	}
+}
+
+func (m *ChatModel) quit() tea.Msg {
+	m.cancel()
+	if m.client != nil {
+		m.client.CancelRequest()
+	}
+	return tea.Quit()
+}
+
+func (m *ChatModel) Err() error {
+	return m.err
+}
+
+func (m *ChatModel) viewportNeeded() bool {
+	return m.glamHeight > m.height
+}
+
+func (m *ChatModel) footer() string {
+	footer := m.Provider + " " + m.Model
+	if m.Usage != (provider.Usage{}) {
+		footer += " · " + m.Usage.String()
+	}
+	if m.step.Step > 1 {
+		footer += fmt.Sprintf(" · step %d/%d", m.step.Step, m.step.MaxSteps)
+		if len(m.step.Tools) > 0 {
+			footer += " after " + strings.Join(m.step.Tools, ", ")
+		}
+	}
+	if m.status != "" {
+		footer += " · " + m.status
+	}
+	if m.warning != "" {
+		footer += " · " + message.WarningStyle.Render(m.warning)
+	}
+	return "  " + message.SecondaryStyle.Render(footer)
+}
+
+func (m *ChatModel) approvalView() string {
+	preview, err := m.glam.Render(m.approval.request.Preview)
+	if err != nil {
+		preview = m.approval.request.Preview
+	}
+	preview = strings.TrimRightFunc(preview, unicode.IsSpace)
+
+	lines := strings.Split(preview, "\n")
+	if maxLines := m.height - 6; maxLines > 0 && len(lines) > maxLines {
+		lines = append(lines[:maxLines], "  …")
+	}
+	preview = m.renderer.NewStyle().MaxWidth(m.width).Render(strings.Join(lines, "\n"))
+
+	prompt := "Allow " + m.approval.request.Tool + "?  y yes · a yes for this session · n no · f no, with feedback"
+	if m.feedback.Focused() {
+		prompt = "Feedback (enter to send, esc to go back): " + m.feedback.View()
+	}
+
+	return message.ToolStyle.Render(preview + "\n\n" + "  " + message.SecondaryStyle.Render(prompt))
+}
+
+func (m *ChatModel) View() string {
+	if m.approval != nil {
+		return m.approvalView()
+	}
+
+	switch m.state {
+	case requestState:
+		return m.anim.View()
+	case responseState:
+		if m.viewportNeeded() {
+			return message.AssistantStyle.Render(m.glamViewport.View() + "\n\n" + m.footer() + " " + m.anim.View())
+		}
+
+		return message.AssistantStyle.Render(m.glamOutput + "\n\n" + m.footer() + " " + m.anim.View())
+	case doneState:
+		return ""
+	}
+
+	return ""
+}
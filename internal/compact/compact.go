@@ -0,0 +1,182 @@
+package compact
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/TZGyn/kode/internal/tokens"
+)
+
+const (
+	defaultThreshold = 0.8
+	defaultKeepTurns = 2
+
+	// overhead accounts for the system prompt and the tool declarations sent
+	// with every request.
+	overhead = 2000
+	// resultLimit caps the tool results sent to the summarizer, in bytes.
+	resultLimit = 2000
+
+	summaryPrefix = "Summary of the earlier conversation:\n\n"
+)
+
+const summaryPrompt = `Summarize the conversation above so that it can be continued without it.
+Keep the goals and instructions of the user, the decisions made, the files read or changed and what matters about them, the current state of the work and what remains to be done.
+Be concise and use markdown.`
+
+var ErrNothingToCompact = errors.New("nothing to compact")
+
+// Compactor replaces the older turns of a history by a summary written by
+// the model, so that long sessions fit in the context window.
+type Compactor struct {
+	provider  provider.Provider
+	counter   tokens.Counter
+	window    int64
+	threshold float64
+	keep      int
+}
+
+// New returns a compactor for the history of info, summarizing with p.
+func New(p provider.Provider, info models.Model, c config.Compaction) *Compactor {
+	if c.Threshold <= 0 || c.Threshold > 1 {
+		c.Threshold = defaultThreshold
+	}
+	if c.KeepTurns <= 0 {
+		c.KeepTurns = defaultKeepTurns
+	}
+
+	return &Compactor{
+		provider:  p,
+		counter:   tokens.ForModel(info),
+		window:    info.ContextWindow,
+		threshold: c.Threshold,
+		keep:      c.KeepTurns,
+	}
+}
+
+// Estimate returns the number of tokens messages take in a request.
+func (c *Compactor) Estimate(messages model.ChatMessages) int64 {
+	return tokens.Messages(c.counter, messages) + overhead
+}
+
+// Maybe compacts messages once they reach the threshold of the window.
+func (c *Compactor) Maybe(ctx context.Context, messages *model.ChatMessages, emit provider.EmitFunc) error {
+	if c.window <= 0 || c.Estimate(*messages) < int64(float64(c.window)*c.threshold) {
+		return nil
+	}
+
+	_, _, err := c.Compact(ctx, messages, emit)
+	if errors.Is(err, ErrNothingToCompact) {
+		return nil
+	}
+	return err
+}
+
+// Compact replaces every turn but the last ones by a summary, followed by the
+// pinned turns kept verbatim. It returns the estimated size of the history
+// before and after.
+func (c *Compactor) Compact(ctx context.Context, messages *model.ChatMessages, emit provider.EmitFunc) (int64, int64, error) {
+	turns := split(*messages)
+	if len(turns) <= c.keep {
+		return 0, 0, ErrNothingToCompact
+	}
+	old, recent := turns[:len(turns)-c.keep], turns[len(turns)-c.keep:]
+
+	request := model.ChatMessages{}
+	pinned := model.ChatMessages{}
+	for _, turn := range old {
+		request = append(request, truncate(turn)...)
+		if isPinned(turn) {
+			pinned = append(pinned, turn...)
+		}
+	}
+	request = append(request, userMessage(summaryPrompt))
+
+	if err := provider.Step(ctx); err != nil {
+		return 0, 0, err
+	}
+
+	// Only the usage of the summary is reported, its text is not part of
+	// the answer.
+	reply, err := c.provider.Stream(ctx, provider.Request{Messages: request, NoTools: true}, func(event provider.Event) {
+		if _, ok := event.(provider.UsageEvent); ok {
+			emit(event)
+		}
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	summary := []string{}
+	for _, part := range reply.Parts {
+		if part.Type == "text" && part.Text != "" {
+			summary = append(summary, part.Text)
+		}
+	}
+	if len(summary) == 0 {
+		return 0, 0, errors.New("the model returned an empty summary")
+	}
+
+	compacted := model.ChatMessages{userMessage(summaryPrefix + strings.Join(summary, "\n"))}
+	compacted = append(compacted, pinned...)
+	for _, turn := range recent {
+		compacted = append(compacted, turn...)
+	}
+
+	before, after := c.Estimate(*messages), c.Estimate(compacted)
+	*messages = compacted
+	emit(provider.CompactionEvent{Before: before, After: after})
+
+	return before, after, nil
+}
+
+// split cuts the history in turns, each starting with a user message.
+func split(messages model.ChatMessages) []model.ChatMessages {
+	turns := []model.ChatMessages{}
+	for _, message := range messages {
+		if message.Role == "user" || len(turns) == 0 {
+			turns = append(turns, model.ChatMessages{})
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], message)
+	}
+	return turns
+}
+
+func isPinned(turn model.ChatMessages) bool {
+	for _, message := range turn {
+		if message.Pinned {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate returns a copy of turn with long tool results cut, they matter
+// less to the summary than the room they take.
+func truncate(turn model.ChatMessages) model.ChatMessages {
+	result := model.ChatMessages{}
+	for _, message := range turn {
+		copied := *message
+		copied.Parts = []*model.ChatPart{}
+		for _, part := range message.Parts {
+			if part.Type == "tool-result" && len(part.ResultText()) > resultLimit {
+				part = model.NewToolResult(part.ToolCallID, part.ToolCallName, part.ResultText()[:resultLimit]+"\n... (truncated)")
+			}
+			copied.Parts = append(copied.Parts, part)
+		}
+		result = append(result, &copied)
+	}
+	return result
+}
+
+func userMessage(text string) *model.ChatMessage {
+	return &model.ChatMessage{
+		Role:  "user",
+		Parts: []*model.ChatPart{{Type: "text", Text: text}},
+	}
+}
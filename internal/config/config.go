@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 
 	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/permission"
 	"github.com/adrg/xdg"
 )
 
@@ -17,6 +18,166 @@ type Config struct {
 	ANTHROPIC_API_KEY string               `json:"ANTHROPIC_API_KEY"`
 	DEFAULT_PROVIDER  models.ModelProvider `json:"default_provider"`
 	DEFAULT_MODEL     models.ModelID       `json:"default_model"`
+	Permissions       permission.Policy    `json:"permissions"`
+
+	// ReadableDirectories can be read by the tools in addition to the
+	// workspace, which is the only place they can write to.
+	ReadableDirectories []string `json:"readable_directories"`
+
+	Budget Budget `json:"budget"`
+
+	// MaxSteps is the number of model requests a turn can make before the
+	// model is asked to wrap up.
+	MaxSteps int `json:"max_steps,omitempty"`
+
+	Compaction Compaction `json:"compaction"`
+
+	Retry Retry `json:"retry"`
+	// Fallback lists the models that continue the conversation, in order,
+	// once the retries of the previous one are exhausted.
+	Fallback []FallbackModel `json:"fallback,omitempty"`
+
+	Azure   Azure   `json:"azure"`
+	Bedrock Bedrock `json:"bedrock"`
+	Vertex  Vertex  `json:"vertex"`
+	Mock    Mock    `json:"mock"`
+
+	// Providers declares additional providers by name, such as self-hosted
+	// models served behind an OpenAI compatible endpoint.
+	Providers map[models.ModelProvider]Provider `json:"providers,omitempty"`
+}
+
+// Azure configures Azure OpenAI, Endpoint being the resource endpoint such
+// as https://<resource>.openai.azure.com. Deployments maps model IDs, such as
+// gpt-4.1, to the name of their deployment, models without one are expected
+// to be deployed under their ID. Unset values are read from
+// AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_API_KEY and OPENAI_API_VERSION.
+type Azure struct {
+	Endpoint    string                    `json:"endpoint,omitempty"`
+	APIKey      string                    `json:"api_key,omitempty"`
+	APIVersion  string                    `json:"api_version,omitempty"`
+	Deployments map[models.ModelID]string `json:"deployments,omitempty"`
+}
+
+// Bedrock configures AWS Bedrock. Credentials come from the standard AWS
+// sources, the environment, the shared config and credentials files or the
+// instance role, Profile selecting a profile of the shared files. Region
+// defaults to the one of the AWS config. Models only served through
+// cross-region inference profiles use the profile of the geography of the
+// region. ModelIDs overrides the Bedrock ID of models, to use another
+// inference profile such as "global.anthropic.claude-sonnet-4-20250514-v1:0".
+// Endpoint replaces the regional endpoint, for VPC endpoints.
+type Bedrock struct {
+	Region   string                    `json:"region,omitempty"`
+	Profile  string                    `json:"profile,omitempty"`
+	Endpoint string                    `json:"endpoint,omitempty"`
+	ModelIDs map[models.ModelID]string `json:"model_ids,omitempty"`
+}
+
+// Vertex configures Vertex AI, serving the Gemini models as the vertex provider
+// and the Anthropic ones as vertex-anthropic. Credentials is the path of a
+// service account JSON key, Application Default Credentials are used without
+// it. Unset values are read from GOOGLE_CLOUD_PROJECT and
+// GOOGLE_CLOUD_LOCATION, the project defaulting to the one of the service
+// account. Endpoint replaces the regional endpoint, for Private Service
+// Connect.
+type Vertex struct {
+	Project     string `json:"project,omitempty"`
+	Location    string `json:"location,omitempty"`
+	Credentials string `json:"credentials,omitempty"`
+	Endpoint    string `json:"endpoint,omitempty"`
+}
+
+// Mock configures the mock provider, Script being the path of the YAML or
+// JSON fixture it replays, KODE_MOCK_SCRIPT when unset.
+type Mock struct {
+	Script string `json:"script,omitempty"`
+}
+
+// Provider is a provider declared in the config. Type is the protocol it
+// speaks, openai-compatible, or ollama to configure the Ollama provider whose
+// models are discovered. APIKey and the Headers values are expanded from the
+// environment, as in "$VLLM_API_KEY". KeepAlive is how long Ollama keeps a
+// model loaded after a request, such as "30m", negative to keep it loaded.
+type Provider struct {
+	Type      string            `json:"type"`
+	BaseURL   string            `json:"base_url"`
+	APIKey    string            `json:"api_key,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	KeepAlive string            `json:"keep_alive,omitempty"`
+	Models    []Model           `json:"models"`
+}
+
+// Model is a model of a declared provider. APIModel defaults to ID and
+// Tools to true, declared models cost nothing.
+type Model struct {
+	ID                  models.ModelID `json:"id"`
+	Name                string         `json:"name,omitempty"`
+	APIModel            string         `json:"api_model,omitempty"`
+	ContextWindow       int64          `json:"context_window,omitempty"`
+	DefaultMaxTokens    int64          `json:"default_max_tokens,omitempty"`
+	Tools               *bool          `json:"tools,omitempty"`
+	CanReason           bool           `json:"can_reason,omitempty"`
+	SupportsAttachments bool           `json:"supports_attachments,omitempty"`
+}
+
+// Info returns the model as listed in the model table under provider.
+func (m Model) Info(provider models.ModelProvider) models.Model {
+	info := models.Model{
+		ID:                  m.ID,
+		Name:                m.Name,
+		Provider:            provider,
+		APIModel:            m.APIModel,
+		ContextWindow:       m.ContextWindow,
+		DefaultMaxTokens:    m.DefaultMaxTokens,
+		CanReason:           m.CanReason,
+		SupportsAttachments: m.SupportsAttachments,
+		SupportsTools:       m.Tools == nil || *m.Tools,
+	}
+	if info.Name == "" {
+		info.Name = string(m.ID)
+	}
+	if info.APIModel == "" {
+		info.APIModel = string(m.ID)
+	}
+	return info
+}
+
+// Compaction configures the summaries replacing older turns once the history
+// reaches Threshold of the context window, 0.8 when unset. The last KeepTurns
+// turns, 2 when unset, are kept verbatim. Summaries are written by Provider
+// and Model when set, by the current model otherwise.
+type Compaction struct {
+	Provider  models.ModelProvider `json:"provider,omitempty"`
+	Model     models.ModelID       `json:"model,omitempty"`
+	Threshold float64              `json:"threshold,omitempty"`
+	KeepTurns int                  `json:"keep_turns,omitempty"`
+}
+
+// Retry configures retrying the requests failing because the provider is
+// rate limited, overloaded or unavailable. MaxRetries is 2 when unset, none
+// when negative. Delays, such as "1s", double from InitialDelay, 1s when
+// unset, up to MaxDelay, 30s when unset. The delay asked by the provider is
+// waited instead, the next fallback model being used right away when it is
+// longer than MaxDelay.
+type Retry struct {
+	MaxRetries   int    `json:"max_retries,omitempty"`
+	InitialDelay string `json:"initial_delay,omitempty"`
+	MaxDelay     string `json:"max_delay,omitempty"`
+}
+
+type FallbackModel struct {
+	Provider models.ModelProvider `json:"provider"`
+	Model    models.ModelID       `json:"model"`
+}
+
+// Budget limits spending in dollars, zero meaning no limit. Warn is the
+// fraction of a limit at which a warning is shown, 0.8 when unset.
+type Budget struct {
+	Session float64 `json:"session,omitempty"`
+	Day     float64 `json:"day,omitempty"`
+	Project float64 `json:"project,omitempty"`
+	Warn    float64 `json:"warn,omitempty"`
 }
 
 func New() (*Config, error) {
@@ -15,5 +15,15 @@ var AssistantStyle = lipgloss.NewStyle().
 	BorderStyle(lipgloss.ThickBorder()).
 	BorderForeground(lipgloss.Color("#F57FE0"))
 
+var ToolStyle = lipgloss.NewStyle().
+	MarginTop(1).
+	MarginBottom(1).
+	BorderLeft(true).
+	BorderStyle(lipgloss.ThickBorder()).
+	BorderForeground(lipgloss.Color("#F5C542"))
+
+var WarningStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("#F5C542"))
+
 var SecondaryStyle = lipgloss.NewStyle().
 	Foreground(lipgloss.Color("#848484"))
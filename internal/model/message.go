@@ -3,291 +3,130 @@ package model
 import (
 	"encoding/json"
 	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
 
-	"github.com/anthropics/anthropic-sdk-go"
-	"github.com/openai/openai-go"
-	"google.golang.org/genai"
+	"github.com/TZGyn/kode/internal/models"
 )
 
 type ChatMessages []*ChatMessage
 
+// ChatMessage is a message of the provider-neutral history. Role is user,
+// assistant, or tool for the messages holding tool results. Provider and
+// Model are set on assistant messages to the model that wrote them. Pinned
+// messages are kept verbatim when the history is compacted.
 type ChatMessage struct {
-	Role  string
-	Parts []*ChatPart
+	Role     string               `json:"role"`
+	Parts    []*ChatPart          `json:"parts"`
+	Provider models.ModelProvider `json:"provider,omitempty"`
+	Model    models.ModelID       `json:"model,omitempty"`
+	Pinned   bool                 `json:"pinned,omitempty"`
 }
 
+// ChatPart is a part of a message, its Type is text, reasoning, tool-call,
+// tool-result or file.
+//
+// Signature is the signature a provider puts on its reasoning to have it
+// sent back, or the encrypted reasoning when Reasoning is empty. Reasoning is
+// only sent back to the provider that wrote it.
+//
+// Files are attachments, Data holds their content and MediaType its type.
 type ChatPart struct {
-	Type           string
-	Text           string
-	Reasoning      string
-	ToolCallName   string
-	ToolCallID     string
-	ToolCallArgs   map[string]any
-	ToolCallResult map[string]any
+	Type           string         `json:"type"`
+	Text           string         `json:"text,omitempty"`
+	Reasoning      string         `json:"reasoning,omitempty"`
+	Signature      string         `json:"signature,omitempty"`
+	ToolCallName   string         `json:"tool_call_name,omitempty"`
+	ToolCallID     string         `json:"tool_call_id,omitempty"`
+	ToolCallArgs   map[string]any `json:"tool_call_args,omitempty"`
+	ToolCallResult map[string]any `json:"tool_call_result,omitempty"`
+	Filename       string         `json:"filename,omitempty"`
+	MediaType      string         `json:"media_type,omitempty"`
+	Data           []byte         `json:"data,omitempty"`
 }
 
-func (c *ChatMessages) ConvertToGoogleMessages() ([]*genai.Content, error) {
-	googleMessages := []*genai.Content{}
-
-	for _, content := range *c {
-		parts := []*genai.Part{}
-
-		for _, part := range content.Parts {
-			if part.Type == "text" {
-				parts = append(parts, &genai.Part{
-					Text: part.Text,
-				})
-			}
-			if part.Type == "tool-call" {
-				parts = append(parts, &genai.Part{
-					FunctionCall: &genai.FunctionCall{
-						Name: part.ToolCallName,
-						ID:   part.ToolCallID,
-						Args: part.ToolCallArgs,
-					},
-				})
-			}
-			if part.Type == "tool-result" {
-				parts = append(parts, &genai.Part{
-					FunctionResponse: &genai.FunctionResponse{
-						Name:     part.ToolCallName,
-						ID:       part.ToolCallID,
-						Response: part.ToolCallResult,
-					},
-				})
-			}
+// invalidArgs is the key the arguments of a tool call that are not a JSON
+// object are kept under, so the call stays in the history of every provider.
+const invalidArgs = "invalid_arguments"
+
+// NewToolCall returns the tool-call part of arguments encoded in JSON. Calls
+// with malformed arguments are kept, ArgsError tells the model what is wrong.
+func NewToolCall(id string, name string, arguments string) *ChatPart {
+	args := map[string]any{}
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			args = map[string]any{invalidArgs: arguments}
 		}
-
-		googleMessages = append(googleMessages, &genai.Content{
-			Role:  content.Role,
-			Parts: parts,
-		})
 	}
 
-	return googleMessages, nil
+	return &ChatPart{
+		Type:         "tool-call",
+		ToolCallID:   id,
+		ToolCallName: name,
+		ToolCallArgs: args,
+	}
 }
 
-func (c *ChatMessages) AddGoogleMessages(messages []*genai.Content) error {
-	for _, content := range messages {
-		role := ""
-		if content.Role == "user" {
-			role = "user"
-		} else {
-			role = "assistant"
-		}
-
-		parts := []*ChatPart{}
-
-		for _, part := range content.Parts {
-			if len(part.Text) > 0 {
-				parts = append(parts, &ChatPart{
-					Type: "text",
-					Text: part.Text,
-				})
-			}
-			if part.FunctionCall != nil {
-				parts = append(parts, &ChatPart{
-					Type:         "tool-call",
-					ToolCallName: part.FunctionCall.Name,
-					ToolCallID:   part.FunctionCall.ID,
-					ToolCallArgs: part.FunctionCall.Args,
-				})
-			}
-			if part.FunctionResponse != nil {
-				parts = append(parts, &ChatPart{
-					Type:           "tool-result",
-					ToolCallName:   part.FunctionResponse.Name,
-					ToolCallID:     part.FunctionResponse.ID,
-					ToolCallResult: part.FunctionResponse.Response,
-				})
-			}
-		}
-
-		*c = append(*c, &ChatMessage{Role: role, Parts: parts})
+// ArgsError returns the error to answer a tool call whose arguments were
+// malformed with, empty for valid calls.
+func (p *ChatPart) ArgsError() string {
+	arguments, ok := p.ToolCallArgs[invalidArgs].(string)
+	if !ok || len(p.ToolCallArgs) != 1 {
+		return ""
 	}
-
-	return nil
+	return "Not executed, the arguments are not a valid JSON object: " + arguments
 }
 
-func (c *ChatMessages) ConvertToOpenAIMessages() ([]openai.ChatCompletionMessageParamUnion, error) {
-	openAIMessages := []openai.ChatCompletionMessageParamUnion{}
-
-	for _, content := range *c {
-		for _, part := range content.Parts {
-			if part.Type == "text" {
-				if content.Role == "user" {
-					openAIMessages = append(openAIMessages, openai.UserMessage(part.Text))
-				} else if content.Role == "assistant" {
-					openAIMessages = append(openAIMessages, openai.AssistantMessage(part.Text))
-				}
-			}
-			if part.Type == "tool-call" {
-			}
-			if part.Type == "tool-result" {
-				data, err := json.Marshal(part.ToolCallResult)
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
-
-				openAIMessages = append(openAIMessages, openai.ToolMessage(string(data), part.ToolCallID))
-			}
-		}
+// NewToolResult returns the tool-result part answering a tool call. Results
+// are stored as {"result": text}, the shape every provider can send back.
+func NewToolResult(id string, name string, result string) *ChatPart {
+	return &ChatPart{
+		Type:           "tool-result",
+		ToolCallID:     id,
+		ToolCallName:   name,
+		ToolCallResult: map[string]any{"result": result},
 	}
-
-	return openAIMessages, nil
 }
 
-func (c *ChatMessages) AddOpenAIMessages(messages []openai.ChatCompletionMessageParamUnion) error {
-	for _, message := range messages {
-		if message.OfSystem != nil {
-			continue
-		}
-		parts := []*ChatPart{}
-		if message.OfUser != nil {
-			parts = append(parts, &ChatPart{
-				Type: "text",
-				Text: message.OfUser.Content.OfString.String(),
-			})
-		}
-		if message.OfTool != nil {
-			result := make(map[string]any)
-			data, err := message.OfTool.Content.MarshalJSON()
-
-			if err != nil {
-				fmt.Println(err)
-				continue
-			}
-
-			err = json.Unmarshal(data, &result)
-			if err != nil {
-				fmt.Println(err)
-				continue
-			}
-
-			parts = append(parts, &ChatPart{
-				Type:           "tool-result",
-				ToolCallID:     message.OfTool.ToolCallID,
-				ToolCallResult: result,
-			})
-		}
-		if message.OfAssistant != nil {
-			parts = append(parts, &ChatPart{
-				Type: "text",
-				Text: message.OfAssistant.Content.OfString.String(),
-			})
-			toolCalls := message.OfAssistant.ToolCalls
-			if len(toolCalls) > 0 {
-
-				for _, toolCall := range toolCalls {
-					var args map[string]any
-					json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
-
-					parts = append(parts, &ChatPart{
-						Type:         "tool-call",
-						ToolCallID:   toolCall.ID,
-						ToolCallName: toolCall.Function.Name,
-						ToolCallArgs: args,
-					})
-				}
-			}
-		}
-
-		*c = append(*c, &ChatMessage{Role: *message.GetRole(), Parts: parts})
+// ResultText returns the text of a tool-result part.
+func (p *ChatPart) ResultText() string {
+	if result, ok := p.ToolCallResult["result"].(string); ok {
+		return result
 	}
-	return nil
+	data, _ := json.Marshal(p.ToolCallResult)
+	return string(data)
 }
 
-func (c *ChatMessages) ConvertToAnthropicMessages() ([]anthropic.MessageParam, error) {
-	messages := []anthropic.MessageParam{}
-
-	for _, content := range *c {
-		for _, part := range content.Parts {
-			if part.Type == "text" {
-				if content.Role == "user" {
-					messages = append(messages, anthropic.NewUserMessage(anthropic.NewTextBlock(part.Text)))
-				} else if content.Role == "assistant" {
-					messages = append(messages, anthropic.NewAssistantMessage(anthropic.NewTextBlock(part.Text)))
-				}
-			}
-			if part.Type == "tool-call" {
-				messages = append(messages,
-					anthropic.NewAssistantMessage(
-						anthropic.NewToolUseBlock(part.ToolCallID, part.ToolCallArgs, part.ToolCallName),
-					),
-				)
-			}
-			if part.Type == "tool-result" {
-				result, err := json.Marshal(part.ToolCallResult)
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
-				messages = append(messages,
-					anthropic.NewAssistantMessage(
-						anthropic.NewToolResultBlock(part.ToolCallID, string(result), false),
-					),
-				)
-			}
-		}
+// NewFile returns the file part attaching data, its media type guessed from
+// the extension of filename or else from data.
+func NewFile(filename string, data []byte) *ChatPart {
+	mediaType, _, _ := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(filename)))
+	if mediaType == "" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
 	}
 
-	return messages, nil
+	return &ChatPart{
+		Type:      "file",
+		Filename:  filepath.Base(filename),
+		MediaType: mediaType,
+		Data:      data,
+	}
 }
 
-func (c *ChatMessages) AddAnthropicMessages(messages []anthropic.MessageParam) error {
-	for _, message := range messages {
-		parts := []*ChatPart{}
-		for _, content := range message.Content {
-			if content.OfText != nil {
-				parts = append(parts, &ChatPart{Type: "text", Text: *content.GetText()})
-			}
-			if content.OfToolUse != nil {
-				args := make(map[string]any)
-				data, err := content.OfToolUse.MarshalJSON()
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
-
-				err = json.Unmarshal(data, &args)
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
-
-				parts = append(parts, &ChatPart{
-					Type:         "tool-call",
-					ToolCallName: content.OfToolUse.Name,
-					ToolCallID:   content.OfToolUse.ID,
-					ToolCallArgs: args,
-				})
-			}
-			if content.OfToolResult != nil {
-				result := make(map[string]any)
-				data, err := content.OfToolResult.MarshalJSON()
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
-
-				err = json.Unmarshal(data, &result)
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
-
-				parts = append(parts, &ChatPart{
-					Type:           "tool-result",
-					ToolCallID:     content.OfToolResult.ToolUseID,
-					ToolCallResult: result,
-				})
-			}
-		}
+// IsText reports whether a file part holds text, which providers only taking
+// images and documents get inlined.
+func (p *ChatPart) IsText() bool {
+	return strings.HasPrefix(p.MediaType, "text/") ||
+		p.MediaType == "application/json" ||
+		p.MediaType == "application/xml" ||
+		p.MediaType == "application/javascript"
+}
 
-		*c = append(*c, &ChatMessage{Role: string(message.Role), Parts: parts})
-	}
-	return nil
+// FileText returns a text file part as text, headed by its name.
+func (p *ChatPart) FileText() string {
+	return "<file name=\"" + p.Filename + "\">\n" + string(p.Data) + "\n</file>"
 }
 
 func (c *ChatMessages) Print() {
@@ -27,6 +27,7 @@ var AnthropicModels = map[ModelProvider][]Model{
 			ContextWindow:       200000,
 			DefaultMaxTokens:    5000,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  Claude3Haiku,
@@ -40,6 +41,7 @@ var AnthropicModels = map[ModelProvider][]Model{
 			ContextWindow:       200000,
 			DefaultMaxTokens:    4096,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  Claude37Sonnet,
@@ -54,6 +56,7 @@ var AnthropicModels = map[ModelProvider][]Model{
 			DefaultMaxTokens:    50000,
 			CanReason:           true,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  Claude4Sonnet,
@@ -68,6 +71,7 @@ var AnthropicModels = map[ModelProvider][]Model{
 			DefaultMaxTokens:    50000,
 			CanReason:           true,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  Claude4Opus,
@@ -82,6 +86,7 @@ var AnthropicModels = map[ModelProvider][]Model{
 			DefaultMaxTokens:    32000,
 			CanReason:           true,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  Claude35Haiku,
@@ -95,6 +100,7 @@ var AnthropicModels = map[ModelProvider][]Model{
 			ContextWindow:       200000,
 			DefaultMaxTokens:    4096,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  Claude3Opus,
@@ -108,6 +114,7 @@ var AnthropicModels = map[ModelProvider][]Model{
 			ContextWindow:       200000,
 			DefaultMaxTokens:    4096,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 	},
 }
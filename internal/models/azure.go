@@ -0,0 +1,18 @@
+package models
+
+const ProviderAzure ModelProvider = "azure"
+
+// AzureModels are the OpenAI models served by Azure OpenAI, each under the
+// deployment configured for it.
+var AzureModels = map[ModelProvider][]Model{
+	ProviderAzure: azureModels(),
+}
+
+func azureModels() []Model {
+	azure := []Model{}
+	for _, model := range OpenAIModels[ProviderOpenAI] {
+		model.Provider = ProviderAzure
+		azure = append(azure, model)
+	}
+	return azure
+}
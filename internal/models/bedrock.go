@@ -0,0 +1,64 @@
+package models
+
+import "strings"
+
+const ProviderBedrock ModelProvider = "bedrock"
+
+// bedrockIDs are the Bedrock IDs of the Anthropic models.
+var bedrockIDs = map[ModelID]string{
+	Claude35Sonnet: "anthropic.claude-3-5-sonnet-20241022-v2:0",
+	Claude3Haiku:   "anthropic.claude-3-haiku-20240307-v1:0",
+	Claude37Sonnet: "anthropic.claude-3-7-sonnet-20250219-v1:0",
+	Claude35Haiku:  "anthropic.claude-3-5-haiku-20241022-v1:0",
+	Claude3Opus:    "anthropic.claude-3-opus-20240229-v1:0",
+	Claude4Sonnet:  "anthropic.claude-sonnet-4-20250514-v1:0",
+	Claude4Opus:    "anthropic.claude-opus-4-20250514-v1:0",
+}
+
+// bedrockProfiles are the models only served through cross-region inference
+// profiles, whose IDs are prefixed by the geography, such as
+// us.anthropic.claude-sonnet-4-20250514-v1:0.
+var bedrockProfiles = map[ModelID]bool{
+	Claude37Sonnet: true,
+	Claude4Sonnet:  true,
+	Claude4Opus:    true,
+}
+
+// BedrockModelID returns the ID to request the model with in region, the
+// inference profile of the geography of the region for the models served
+// through one.
+func BedrockModelID(m Model, region string) string {
+	if !bedrockProfiles[m.ID] {
+		return m.APIModel
+	}
+
+	geography := "us"
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		geography = "us-gov"
+	case strings.HasPrefix(region, "eu-"):
+		geography = "eu"
+	case strings.HasPrefix(region, "ap-"):
+		geography = "apac"
+	}
+	return geography + "." + m.APIModel
+}
+
+// BedrockModels are the Anthropic models served by AWS Bedrock.
+var BedrockModels = map[ModelProvider][]Model{
+	ProviderBedrock: bedrockModels(),
+}
+
+func bedrockModels() []Model {
+	bedrock := []Model{}
+	for _, model := range AnthropicModels[ProviderAnthropic] {
+		id, ok := bedrockIDs[model.ID]
+		if !ok {
+			continue
+		}
+		model.Provider = ProviderBedrock
+		model.APIModel = id
+		bedrock = append(bedrock, model)
+	}
+	return bedrock
+}
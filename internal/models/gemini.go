@@ -24,6 +24,7 @@ var GeminiModels = map[ModelProvider][]Model{
 			ContextWindow:       1000000,
 			DefaultMaxTokens:    50000,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  Gemini25,
@@ -37,6 +38,7 @@ var GeminiModels = map[ModelProvider][]Model{
 			ContextWindow:       1000000,
 			DefaultMaxTokens:    50000,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  Gemini20Flash,
@@ -50,6 +52,7 @@ var GeminiModels = map[ModelProvider][]Model{
 			ContextWindow:       1000000,
 			DefaultMaxTokens:    6000,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  Gemini20FlashLite,
@@ -63,6 +66,7 @@ var GeminiModels = map[ModelProvider][]Model{
 			ContextWindow:       1000000,
 			DefaultMaxTokens:    6000,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 	},
 }
@@ -0,0 +1,25 @@
+package models
+
+const (
+	ProviderMock ModelProvider = "mock"
+
+	Mock ModelID = "mock"
+)
+
+// MockModels is the scripted model of the mock provider, replaying the
+// responses of a fixture without network access.
+var MockModels = map[ModelProvider][]Model{
+	ProviderMock: {
+		{
+			ID:                  Mock,
+			Name:                "Mock",
+			Provider:            ProviderMock,
+			APIModel:            "mock",
+			ContextWindow:       200_000,
+			DefaultMaxTokens:    5000,
+			CanReason:           true,
+			SupportsAttachments: true,
+			SupportsTools:       true,
+		},
+	},
+}
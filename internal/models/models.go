@@ -20,6 +20,7 @@ type Model struct {
 	DefaultMaxTokens    int64         `json:"default_max_tokens"`
 	CanReason           bool          `json:"can_reason"`
 	SupportsAttachments bool          `json:"supports_attachments"`
+	SupportsTools       bool          `json:"supports_tools"`
 }
 
 var Models = map[ModelProvider][]Model{}
@@ -28,4 +29,23 @@ func init() {
 	maps.Copy(Models, AnthropicModels)
 	maps.Copy(Models, OpenAIModels)
 	maps.Copy(Models, GeminiModels)
+	maps.Copy(Models, AzureModels)
+	maps.Copy(Models, BedrockModels)
+	maps.Copy(Models, VertexModels)
+	maps.Copy(Models, MockModels)
+}
+
+// Add makes models available alongside the static ones, for providers whose
+// models are declared in the config or discovered at startup.
+func Add(provider ModelProvider, models ...Model) {
+	Models[provider] = append(Models[provider], models...)
+}
+
+func Find(provider ModelProvider, id ModelID) (Model, bool) {
+	for _, model := range Models[provider] {
+		if model.ID == id {
+			return model, true
+		}
+	}
+	return Model{}, false
 }
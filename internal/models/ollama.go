@@ -0,0 +1,5 @@
+package models
+
+// ProviderOllama serves the models installed in a local Ollama, they are
+// discovered at startup rather than listed here.
+const ProviderOllama ModelProvider = "ollama"
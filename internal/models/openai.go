@@ -33,6 +33,7 @@ var OpenAIModels = map[ModelProvider][]Model{
 			DefaultMaxTokens:    100_000,
 			CanReason:           true,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  GPT41,
@@ -46,6 +47,7 @@ var OpenAIModels = map[ModelProvider][]Model{
 			ContextWindow:       1_047_576,
 			DefaultMaxTokens:    20000,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  GPT41Mini,
@@ -59,6 +61,7 @@ var OpenAIModels = map[ModelProvider][]Model{
 			ContextWindow:       200_000,
 			DefaultMaxTokens:    20000,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  GPT41Nano,
@@ -72,6 +75,7 @@ var OpenAIModels = map[ModelProvider][]Model{
 			ContextWindow:       1_047_576,
 			DefaultMaxTokens:    20000,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  GPT45Preview,
@@ -85,6 +89,7 @@ var OpenAIModels = map[ModelProvider][]Model{
 			ContextWindow:       128_000,
 			DefaultMaxTokens:    15000,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  GPT4o,
@@ -98,6 +103,7 @@ var OpenAIModels = map[ModelProvider][]Model{
 			ContextWindow:       128_000,
 			DefaultMaxTokens:    4096,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  GPT4oMini,
@@ -110,6 +116,7 @@ var OpenAIModels = map[ModelProvider][]Model{
 			CostPer1MOut:        0.60,
 			ContextWindow:       128_000,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  O1,
@@ -124,6 +131,7 @@ var OpenAIModels = map[ModelProvider][]Model{
 			DefaultMaxTokens:    50000,
 			CanReason:           true,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  O1Pro,
@@ -138,6 +146,7 @@ var OpenAIModels = map[ModelProvider][]Model{
 			DefaultMaxTokens:    50000,
 			CanReason:           true,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  O1Mini,
@@ -152,6 +161,7 @@ var OpenAIModels = map[ModelProvider][]Model{
 			DefaultMaxTokens:    50000,
 			CanReason:           true,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  O3,
@@ -165,6 +175,7 @@ var OpenAIModels = map[ModelProvider][]Model{
 			ContextWindow:       200_000,
 			CanReason:           true,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  O3Mini,
@@ -179,6 +190,7 @@ var OpenAIModels = map[ModelProvider][]Model{
 			DefaultMaxTokens:    50000,
 			CanReason:           true,
 			SupportsAttachments: false,
+			SupportsTools:       true,
 		},
 		{
 			ID:                  O4Mini,
@@ -193,6 +205,7 @@ var OpenAIModels = map[ModelProvider][]Model{
 			DefaultMaxTokens:    50000,
 			CanReason:           true,
 			SupportsAttachments: true,
+			SupportsTools:       true,
 		},
 	},
 }
@@ -0,0 +1,46 @@
+package models
+
+const (
+	ProviderVertex          ModelProvider = "vertex"
+	ProviderVertexAnthropic ModelProvider = "vertex-anthropic"
+)
+
+// vertexAnthropicIDs are the Vertex AI IDs of the Anthropic models.
+var vertexAnthropicIDs = map[ModelID]string{
+	Claude35Sonnet: "claude-3-5-sonnet-v2@20241022",
+	Claude3Haiku:   "claude-3-haiku@20240307",
+	Claude37Sonnet: "claude-3-7-sonnet@20250219",
+	Claude35Haiku:  "claude-3-5-haiku@20241022",
+	Claude3Opus:    "claude-3-opus@20240229",
+	Claude4Sonnet:  "claude-sonnet-4@20250514",
+	Claude4Opus:    "claude-opus-4@20250514",
+}
+
+// VertexModels are the Gemini and Anthropic models served by Vertex AI.
+var VertexModels = map[ModelProvider][]Model{
+	ProviderVertex:          vertexModels(),
+	ProviderVertexAnthropic: vertexAnthropicModels(),
+}
+
+func vertexModels() []Model {
+	vertex := []Model{}
+	for _, model := range GeminiModels[ProviderGemini] {
+		model.Provider = ProviderVertex
+		vertex = append(vertex, model)
+	}
+	return vertex
+}
+
+func vertexAnthropicModels() []Model {
+	vertex := []Model{}
+	for _, model := range AnthropicModels[ProviderAnthropic] {
+		id, ok := vertexAnthropicIDs[model.ID]
+		if !ok {
+			continue
+		}
+		model.Provider = ProviderVertexAnthropic
+		model.APIModel = id
+		vertex = append(vertex, model)
+	}
+	return vertex
+}
@@ -0,0 +1,216 @@
+package permission
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Rule matches tool calls by tool name and by the paths they touch. Empty
+// fields match everything. Path is a glob relative to the repository root
+// where * stays within a directory and ** crosses directories.
+type Rule struct {
+	Tool string `json:"tool,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+// ParseRule parses a rule written as "tool" or "tool:path", as accepted on
+// the command line.
+func ParseRule(s string) (Rule, error) {
+	tool, path, _ := strings.Cut(s, ":")
+	if tool == "" && path == "" {
+		return Rule{}, errors.New("empty permission rule")
+	}
+	return Rule{Tool: tool, Path: path}, nil
+}
+
+type Policy struct {
+	Allow []Rule `json:"allow,omitempty"`
+	Deny  []Rule `json:"deny,omitempty"`
+}
+
+type Decision int
+
+const (
+	Accept Decision = iota
+	AcceptSession
+	Reject
+)
+
+type Request struct {
+	Tool     string
+	Args     map[string]any
+	Paths    []string
+	ReadOnly bool
+	Preview  string
+}
+
+type Response struct {
+	Decision Decision
+	Feedback string
+}
+
+func (r Response) Approved() bool {
+	return r.Decision == Accept || r.Decision == AcceptSession
+}
+
+// Message is the tool result sent back to the model for a rejected call.
+func (r Response) Message() string {
+	if r.Approved() {
+		return ""
+	}
+	if r.Feedback != "" {
+		return "The user rejected this tool call with the following feedback: " + r.Feedback
+	}
+	return "The user rejected this tool call. Do not retry it as is, ask the user how to proceed if unsure"
+}
+
+// Asker prompts the user about a request.
+type Asker func(ctx context.Context, request Request) Response
+
+// Session holds the policy and the tools accepted for the rest of the
+// session.
+type Session struct {
+	policy Policy
+
+	mu       sync.Mutex
+	accepted map[string]bool
+}
+
+func NewSession(policy Policy) *Session {
+	return &Session{
+		policy:   policy,
+		accepted: map[string]bool{},
+	}
+}
+
+// Check decides on a request: deny rules win, then allow rules, read-only
+// tools and tools accepted for the session are approved, and anything else is
+// left to ask. Without ask the request is rejected.
+func (s *Session) Check(ctx context.Context, request Request, ask Asker) Response {
+	for _, rule := range s.policy.Deny {
+		if rule.matches(request, false) {
+			return Response{
+				Decision: Reject,
+				Feedback: "denied by the permission policy",
+			}
+		}
+	}
+
+	for _, rule := range s.policy.Allow {
+		if rule.matches(request, true) {
+			return Response{Decision: Accept}
+		}
+	}
+
+	if request.ReadOnly {
+		return Response{Decision: Accept}
+	}
+
+	s.mu.Lock()
+	accepted := s.accepted[request.Tool]
+	s.mu.Unlock()
+	if accepted {
+		return Response{Decision: Accept}
+	}
+
+	if ask == nil {
+		return Response{
+			Decision: Reject,
+			Feedback: request.Tool + " requires approval, which is not available in this mode",
+		}
+	}
+
+	response := ask(ctx, request)
+	if response.Decision == AcceptSession {
+		s.mu.Lock()
+		s.accepted[request.Tool] = true
+		s.mu.Unlock()
+	}
+
+	return response
+}
+
+// matches reports whether the rule applies to the request. Allow rules need
+// every path to match so a single call cannot sneak in an unlisted file, deny
+// rules apply as soon as one path matches.
+func (r Rule) matches(request Request, all bool) bool {
+	if r.Tool != "" && r.Tool != "*" && r.Tool != request.Tool {
+		return false
+	}
+	if r.Path == "" {
+		return true
+	}
+	if len(request.Paths) == 0 {
+		return false
+	}
+
+	for _, path := range request.Paths {
+		matched := Match(r.Path, path)
+		if all && !matched {
+			return false
+		}
+		if !all && matched {
+			return true
+		}
+	}
+	return all
+}
+
+// Match reports whether path matches the glob pattern.
+func Match(pattern string, path string) bool {
+	path = filepath.ToSlash(filepath.Clean(path))
+	pattern = filepath.ToSlash(pattern)
+
+	expression := "^"
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					expression += "(.*/)?"
+				} else {
+					expression += ".*"
+				}
+			} else {
+				expression += "[^/]*"
+			}
+		case '?':
+			expression += "[^/]"
+		default:
+			expression += regexp.QuoteMeta(string(c))
+		}
+	}
+	expression += "$"
+
+	matched, err := regexp.MatchString(expression, strings.TrimPrefix(path, "./"))
+	return err == nil && matched
+}
+
+type contextKey struct{}
+
+type checker struct {
+	session *Session
+	ask     Asker
+}
+
+// NewContext returns a context carrying the session and the asker used by
+// Check.
+func NewContext(ctx context.Context, session *Session, ask Asker) context.Context {
+	return context.WithValue(ctx, contextKey{}, checker{session: session, ask: ask})
+}
+
+// Check evaluates request against the session carried by ctx. Mutating
+// requests are rejected when ctx carries no session.
+func Check(ctx context.Context, request Request) Response {
+	c, ok := ctx.Value(contextKey{}).(checker)
+	if !ok || c.session == nil {
+		return NewSession(Policy{}).Check(ctx, request, nil)
+	}
+	return c.session.Check(ctx, request, c.ask)
+}
@@ -0,0 +1,190 @@
+package permission
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"main.go", "main.go", true},
+		{"main.go", "./main.go", true},
+		{"main.go", "cmd/main.go", false},
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"*.go", "main.go.orig", false},
+		{"cmd/*", "cmd/root.go", true},
+		{"cmd/*", "cmd/sub/root.go", false},
+		{"cmd/*.go", "cmd/../root.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "internal/tool/run.go", true},
+		{"**/*.go", "internal/tool/run.txt", false},
+		{"internal/**", "internal/tool/run.go", true},
+		{"internal/**", "cmd/root.go", false},
+		{"docs/**/*.md", "docs/guide.md", true},
+		{"docs/**/*.md", "docs/a/b/guide.md", true},
+		{"docs/**/*.md", "docsx/guide.md", false},
+		{"file?.txt", "file1.txt", true},
+		{"file?.txt", "file10.txt", false},
+		{"file?.txt", "file/.txt", false},
+		{"a+b(c).txt", "a+b(c).txt", true},
+		{"a.txt", "abtxt", false},
+	}
+
+	for _, test := range tests {
+		if got := Match(test.pattern, test.path); got != test.want {
+			t.Errorf("Match(%q, %q) = %t, want %t", test.pattern, test.path, got, test.want)
+		}
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	for input, want := range map[string]Rule{
+		"run_command":          {Tool: "run_command"},
+		"edit_file:**/*.go":    {Tool: "edit_file", Path: "**/*.go"},
+		":docs/**":             {Path: "docs/**"},
+		"*:internal/secret/**": {Tool: "*", Path: "internal/secret/**"},
+	} {
+		rule, err := ParseRule(input)
+		if err != nil || rule != want {
+			t.Errorf("ParseRule(%q) = %+v, %v, want %+v", input, rule, err, want)
+		}
+	}
+
+	if _, err := ParseRule(""); err == nil {
+		t.Error("ParseRule of an empty rule succeeded")
+	}
+}
+
+// never returns an asker failing the test, for requests decided by the policy.
+func never(t *testing.T) Asker {
+	return func(ctx context.Context, request Request) Response {
+		t.Errorf("asked about %s %v", request.Tool, request.Paths)
+		return Response{Decision: Reject}
+	}
+}
+
+func TestCheckPolicy(t *testing.T) {
+	session := NewSession(Policy{
+		Allow: []Rule{{Tool: "edit_file", Path: "**/*.go"}, {Tool: "run_command"}, {Path: "docs/**"}},
+		Deny:  []Rule{{Tool: "*", Path: "**/secret*"}, {Tool: "run_command", Path: "deploy/**"}},
+	})
+
+	tests := []struct {
+		name    string
+		request Request
+		want    Decision
+	}{
+		{"allowed tool and path", Request{Tool: "edit_file", Paths: []string{"cmd/root.go"}}, Accept},
+		{"allowed tool", Request{Tool: "run_command"}, Accept},
+		{"allowed path for any tool", Request{Tool: "create_file", Paths: []string{"docs/guide.md"}}, Accept},
+		{"deny beats allow", Request{Tool: "edit_file", Paths: []string{"internal/secrets.go"}}, Reject},
+		{"deny beats an allowed tool", Request{Tool: "run_command", Paths: []string{"deploy/prod.sh"}}, Reject},
+		{"deny on a single path", Request{Tool: "apply_patch", Paths: []string{"docs/guide.md", "config/secret.json"}}, Reject},
+		{"deny beats read-only", Request{Tool: "cat_file", Paths: []string{"secret.txt"}, ReadOnly: true}, Reject},
+		{"read-only", Request{Tool: "cat_file", Paths: []string{"main.go"}, ReadOnly: true}, Accept},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if response := session.Check(t.Context(), test.request, never(t)); response.Decision != test.want {
+				t.Errorf("Check = %+v, want decision %d", response, test.want)
+			}
+		})
+	}
+}
+
+// TestCheckAllowEveryPath asks about calls with a single path outside of the
+// allow rule.
+func TestCheckAllowEveryPath(t *testing.T) {
+	session := NewSession(Policy{Allow: []Rule{{Tool: "apply_patch", Path: "**/*.go"}}})
+
+	asked := 0
+	ask := func(ctx context.Context, request Request) Response {
+		asked++
+		return Response{Decision: Reject}
+	}
+
+	for _, paths := range [][]string{{"a.go", "Makefile"}, {"Makefile", "a.go"}, nil} {
+		if response := session.Check(t.Context(), Request{Tool: "apply_patch", Paths: paths}, ask); response.Approved() {
+			t.Errorf("Check of %v approved", paths)
+		}
+	}
+	if asked != 3 {
+		t.Errorf("asked %d times, want 3", asked)
+	}
+
+	if response := session.Check(t.Context(), Request{Tool: "apply_patch", Paths: []string{"a.go", "cmd/b.go"}}, never(t)); !response.Approved() {
+		t.Errorf("Check with every path allowed = %+v", response)
+	}
+}
+
+func TestCheckAcceptSession(t *testing.T) {
+	policy := Policy{}
+	session := NewSession(policy)
+
+	asked := 0
+	ask := func(ctx context.Context, request Request) Response {
+		asked++
+		return Response{Decision: AcceptSession}
+	}
+
+	request := Request{Tool: "run_command", Args: map[string]any{"command": "go test ./..."}}
+	for range 3 {
+		if response := session.Check(t.Context(), request, ask); !response.Approved() {
+			t.Fatalf("Check = %+v, want it approved", response)
+		}
+	}
+	if asked != 1 {
+		t.Errorf("asked %d times, want once for the session", asked)
+	}
+
+	// The answer only covers the tool it was given for.
+	if session.Check(t.Context(), Request{Tool: "create_file"}, ask); asked != 2 {
+		t.Errorf("asked %d times, want another tool to be asked about", asked)
+	}
+
+	// Nor does it outlive the session.
+	rejected := NewSession(policy).Check(t.Context(), request, nil)
+	if rejected.Approved() {
+		t.Error("a new session approved a tool accepted in another one")
+	}
+}
+
+func TestCheckAccept(t *testing.T) {
+	session := NewSession(Policy{})
+
+	asked := 0
+	ask := func(ctx context.Context, request Request) Response {
+		asked++
+		return Response{Decision: Accept}
+	}
+
+	request := Request{Tool: "run_command"}
+	session.Check(t.Context(), request, ask)
+	session.Check(t.Context(), request, ask)
+	if asked != 2 {
+		t.Errorf("asked %d times, want a single accept not to be remembered", asked)
+	}
+}
+
+func TestCheckWithoutAsker(t *testing.T) {
+	response := Check(t.Context(), Request{Tool: "update_file", Paths: []string{"a.go"}})
+	if response.Approved() || response.Message() == "" {
+		t.Errorf("Check without a session = %+v, want it rejected", response)
+	}
+
+	response = Check(t.Context(), Request{Tool: "cat_file", ReadOnly: true})
+	if !response.Approved() {
+		t.Errorf("Check of a read-only tool without a session = %+v", response)
+	}
+
+	ctx := NewContext(t.Context(), NewSession(Policy{Allow: []Rule{{Tool: "update_file"}}}), nil)
+	if response := Check(ctx, Request{Tool: "update_file", Paths: []string{"a.go"}}); !response.Approved() {
+		t.Errorf("Check allowed by the session policy = %+v", response)
+	}
+}
@@ -2,12 +2,16 @@ package anthropic
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"time"
-
+	"net/http"
+	"strings"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/TZGyn/kode/internal/provider/cassette"
 	"github.com/TZGyn/kode/internal/provider/prompt"
-	"github.com/TZGyn/kode/internal/tool"
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
 )
@@ -15,6 +19,11 @@ import (
 type Config struct {
 	ANTHROPIC_API_KEY string `json:"ANTHROPIC_API_KEY"`
 	Model             string `json:"model"`
+	// Options are applied after the API key, to route requests elsewhere.
+	Options []option.RequestOption `json:"-"`
+	// HTTPClient sends the requests, through the cassette set in the
+	// environment if any.
+	HTTPClient *http.Client `json:"-"`
 }
 
 type AnthropicClient struct {
@@ -24,7 +33,13 @@ type AnthropicClient struct {
 	client anthropic.Client
 	model  anthropic.Model
 
-	Messages []anthropic.MessageParam
+	info         models.Model
+	capabilities provider.Capabilities
+	usage        provider.Usage
+}
+
+func init() {
+	provider.Register(models.ProviderAnthropic, New)
 }
 
 func DefaultConfig(apiKey string, model string) Config {
@@ -34,6 +49,18 @@ func DefaultConfig(apiKey string, model string) Config {
 	}
 }
 
+func New(c *config.Config, m models.Model) (provider.Provider, error) {
+	client, err := Create(DefaultConfig(c.ANTHROPIC_API_KEY, m.APIModel))
+	if err != nil {
+		return nil, err
+	}
+
+	client.info = m
+	client.capabilities = provider.CapabilitiesOf(m)
+
+	return client, nil
+}
+
 func Create(config Config) (*AnthropicClient, error) {
 	model := anthropic.Model(config.Model)
 
@@ -41,11 +68,19 @@ func Create(config Config) (*AnthropicClient, error) {
 		return nil, errors.New("invalid model")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	httpClient, err := cassette.Client(config.HTTPClient)
+	if err != nil {
+		return nil, err
+	}
 
-	client := anthropic.NewClient(
-		option.WithAPIKey(config.ANTHROPIC_API_KEY),
-	)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Failed requests are retried by provider.Chain, as configured.
+	options := []option.RequestOption{option.WithAPIKey(config.ANTHROPIC_API_KEY), option.WithMaxRetries(0)}
+	if httpClient != nil {
+		options = append(options, option.WithHTTPClient(httpClient))
+	}
+	client := anthropic.NewClient(append(options, config.Options...)...)
 
 	return &AnthropicClient{
 		context:       ctx,
@@ -57,56 +92,93 @@ func Create(config Config) (*AnthropicClient, error) {
 	}, nil
 }
 
-func (c *AnthropicClient) SendMessage(messages []anthropic.MessageParam, response *string) error {
-	c.Messages = messages
-
-	completion, err := c.client.Messages.New(
-		c.context,
-		anthropic.MessageNewParams{
-			Messages: messages,
-			System: []anthropic.TextBlockParam{
-				{
-					Text: prompt.SystemPrompt(),
-				},
-			},
-			Tools:     tools,
-			Model:     c.model,
-			MaxTokens: 5000,
-		},
-	)
+func (c *AnthropicClient) Stream(ctx context.Context, request provider.Request, emit provider.EmitFunc) (*model.ChatMessage, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(c.context, cancel)()
 
+	messages, err := FromChatMessages(request.Messages)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	toolResults := []anthropic.ContentBlockParamUnion{}
-	for _, block := range completion.Content {
-		switch variant := block.AsAny().(type) {
-		case anthropic.TextBlock:
-			*response += block.Text
-		case anthropic.ToolUseBlock:
-			var input map[string]any
-			err := json.Unmarshal([]byte(variant.JSON.Input.Raw()), &input)
-			if err != nil {
-				continue
-			}
+	params := anthropic.MessageNewParams{
+		Messages: messages,
+		System: []anthropic.TextBlockParam{
+			{
+				Text: prompt.SystemPrompt(),
+			},
+		},
+		Tools:     tools,
+		Model:     c.model,
+		MaxTokens: 5000,
+	}
+	if request.NoTools {
+		params.ToolChoice = anthropic.ToolChoiceUnionParam{OfNone: &anthropic.ToolChoiceNoneParam{}}
+	}
+
+	stream := c.client.Messages.NewStreaming(ctx, params)
+
+	completion := anthropic.Message{}
+	for stream.Next() {
+		event := stream.Current()
+		if err := completion.Accumulate(event); err != nil {
+			return nil, err
+		}
 
-			result, err := tool.HandleTool(block.Name, input, response)
-			if err != nil {
-				continue
+		switch variant := event.AsAny().(type) {
+		case anthropic.ContentBlockStartEvent:
+			if toolUse, ok := variant.ContentBlock.AsAny().(anthropic.ToolUseBlock); ok {
+				emit(provider.ToolCallStartEvent{ID: toolUse.ID, Name: toolUse.Name})
+			}
+		case anthropic.ContentBlockDeltaEvent:
+			if delta, ok := variant.Delta.AsAny().(anthropic.TextDelta); ok {
+				emit(provider.TextDeltaEvent{Text: delta.Text})
 			}
-			toolResults = append(toolResults, anthropic.NewToolResultBlock(block.ID, result, false))
 		}
 	}
+	if err := stream.Err(); err != nil {
+		return nil, statusError(err)
+	}
 
-	if len(toolResults) == 0 {
-		return nil
+	usage := provider.Usage{
+		InputTokens:      completion.Usage.InputTokens,
+		OutputTokens:     completion.Usage.OutputTokens,
+		CacheReadTokens:  completion.Usage.CacheReadInputTokens,
+		CacheWriteTokens: completion.Usage.CacheCreationInputTokens,
+	}.Priced(c.info)
+	c.usage.Add(usage)
+	emit(provider.UsageEvent{Usage: usage})
+
+	reply, err := ToChatMessages([]anthropic.MessageParam{completion.ToParam()})
+	if err != nil {
+		return nil, err
 	}
+	reply[0].Provider, reply[0].Model = c.info.Provider, c.info.ID
+	return reply[0], nil
+}
 
-	messages = append(messages, completion.ToParam())
-	messages = append(messages, anthropic.NewUserMessage(toolResults...))
+// statusError exposes the status of API errors, for them to be retried.
+// Errors sent in the stream once it started only tell their type.
+func statusError(err error) error {
+	apiErr := (*anthropic.Error)(nil)
+	if errors.As(err, &apiErr) {
+		var header http.Header
+		if apiErr.Response != nil {
+			header = apiErr.Response.Header
+		}
+		return provider.NewStatusError(apiErr.StatusCode, header, err)
+	}
 
-	return c.SendMessage(messages, response)
+	switch {
+	case strings.Contains(err.Error(), "overloaded_error"):
+		return provider.NewStatusError(529, nil, err)
+	case strings.Contains(err.Error(), "rate_limit_error"):
+		return provider.NewStatusError(http.StatusTooManyRequests, nil, err)
+	case strings.Contains(err.Error(), "api_error"):
+		return provider.NewStatusError(http.StatusInternalServerError, nil, err)
+	}
+	return err
 }
 
 func (c *AnthropicClient) CancelRequest() error {
@@ -115,3 +187,11 @@ func (c *AnthropicClient) CancelRequest() error {
 	}
 	return nil
 }
+
+func (c *AnthropicClient) Usage() provider.Usage {
+	return c.usage
+}
+
+func (c *AnthropicClient) Capabilities() provider.Capabilities {
+	return c.capabilities
+}
@@ -0,0 +1,62 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/anthropics/anthropic-sdk-go/bedrock"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+func init() {
+	provider.Register(models.ProviderBedrock, NewBedrock)
+}
+
+// NewBedrock returns a client of the Claude models served by AWS Bedrock,
+// signing requests with SigV4 from the standard AWS credential sources.
+func NewBedrock(c *config.Config, m models.Model) (provider.Provider, error) {
+	loadOptions := []func(*awsconfig.LoadOptions) error{}
+	if c.Bedrock.Region != "" {
+		loadOptions = append(loadOptions, awsconfig.WithRegion(c.Bedrock.Region))
+	}
+	if c.Bedrock.Profile != "" {
+		loadOptions = append(loadOptions, awsconfig.WithSharedConfigProfile(c.Bedrock.Profile))
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background(), loadOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("bedrock: loading the aws config: %w", err)
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("bedrock: no region, set bedrock.region in the config or AWS_REGION")
+	}
+
+	options := []option.RequestOption{
+		bedrock.WithConfig(cfg),
+		// The Anthropic key is not meant for AWS.
+		option.WithHeaderDel("x-api-key"),
+	}
+	if c.Bedrock.Endpoint != "" {
+		options = append(options, option.WithBaseURL(c.Bedrock.Endpoint))
+	}
+
+	id := models.BedrockModelID(m, cfg.Region)
+	if override, ok := c.Bedrock.ModelIDs[m.ID]; ok {
+		id = override
+	}
+
+	client, err := Create(Config{Model: id, Options: options})
+	if err != nil {
+		return nil, err
+	}
+
+	client.info = m
+	client.capabilities = provider.CapabilitiesOf(m)
+
+	return client, nil
+}
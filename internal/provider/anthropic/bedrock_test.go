@@ -0,0 +1,79 @@
+package anthropic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/provider"
+)
+
+func TestBedrockModelID(t *testing.T) {
+	sonnet, _ := models.Find(models.ProviderBedrock, models.Claude4Sonnet)
+	haiku, _ := models.Find(models.ProviderBedrock, models.Claude35Haiku)
+
+	for _, test := range []struct {
+		model  models.Model
+		region string
+		want   string
+	}{
+		{sonnet, "us-east-1", "us.anthropic.claude-sonnet-4-20250514-v1:0"},
+		{sonnet, "eu-central-1", "eu.anthropic.claude-sonnet-4-20250514-v1:0"},
+		{sonnet, "ap-northeast-1", "apac.anthropic.claude-sonnet-4-20250514-v1:0"},
+		{sonnet, "us-gov-west-1", "us-gov.anthropic.claude-sonnet-4-20250514-v1:0"},
+		{haiku, "eu-central-1", "anthropic.claude-3-5-haiku-20241022-v1:0"},
+	} {
+		if id := models.BedrockModelID(test.model, test.region); id != test.want {
+			t.Errorf("BedrockModelID(%s, %s) = %s, want %s", test.model.ID, test.region, id, test.want)
+		}
+	}
+}
+
+func TestBedrockSignsRequests(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant-not-for-aws")
+
+	requests := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+		http.Error(w, `{"message":"stop here"}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	c := &config.Config{Bedrock: config.Bedrock{Region: "eu-west-1", Endpoint: server.URL}}
+	m, _ := models.Find(models.ProviderBedrock, models.Claude4Sonnet)
+	client, err := NewBedrock(c, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := model.ChatMessages{{Role: "user", Parts: []*model.ChatPart{{Type: "text", Text: "hi"}}}}
+	if _, err := client.Stream(context.Background(), provider.Request{Messages: messages}, func(provider.Event) {}); err == nil {
+		t.Fatal("Stream succeeded against the stub")
+	}
+
+	r := <-requests
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") ||
+		!strings.Contains(authorization, "/eu-west-1/bedrock/aws4_request") {
+		t.Errorf("Authorization = %q, want a SigV4 signature for bedrock in eu-west-1", authorization)
+	}
+	if r.Header.Get("X-Amz-Date") == "" {
+		t.Error("X-Amz-Date is not set")
+	}
+	if r.Header.Get("X-Api-Key") != "" {
+		t.Error("the Anthropic API key was sent to AWS")
+	}
+	if want := "/model/eu.anthropic.claude-sonnet-4-20250514-v1:0/invoke-with-response-stream"; r.URL.Path != want {
+		t.Errorf("path = %s, want %s", r.URL.Path, want)
+	}
+}
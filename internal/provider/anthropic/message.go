@@ -0,0 +1,166 @@
+package anthropic
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+func FromChatMessages(c model.ChatMessages) ([]anthropic.MessageParam, error) {
+	messages := []anthropic.MessageParam{}
+
+	for _, content := range c {
+		blocks := []anthropic.ContentBlockParamUnion{}
+		for _, part := range content.Parts {
+			switch part.Type {
+			case "text":
+				if part.Text != "" {
+					blocks = append(blocks, anthropic.NewTextBlock(part.Text))
+				}
+			case "reasoning":
+				// Thinking is only accepted back with the signature it was
+				// given.
+				if content.Provider != models.ProviderAnthropic || part.Signature == "" {
+					continue
+				}
+				if part.Reasoning == "" {
+					blocks = append(blocks, anthropic.NewRedactedThinkingBlock(part.Signature))
+				} else {
+					blocks = append(blocks, anthropic.NewThinkingBlock(part.Signature, part.Reasoning))
+				}
+			case "file":
+				blocks = append(blocks, fileBlock(part))
+			case "tool-call":
+				args := part.ToolCallArgs
+				if args == nil {
+					args = map[string]any{}
+				}
+				blocks = append(blocks, anthropic.NewToolUseBlock(part.ToolCallID, args, part.ToolCallName))
+			case "tool-result":
+				blocks = append(blocks, anthropic.NewToolResultBlock(part.ToolCallID, part.ResultText(), false))
+			}
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+
+		// Tool results are sent back by the user.
+		if content.Role == "assistant" {
+			messages = append(messages, anthropic.NewAssistantMessage(blocks...))
+		} else {
+			messages = append(messages, anthropic.NewUserMessage(blocks...))
+		}
+	}
+
+	return messages, nil
+}
+
+// fileBlock sends images as images, text files and PDFs as documents titled
+// with the file name.
+func fileBlock(part *model.ChatPart) anthropic.ContentBlockParamUnion {
+	data := base64.StdEncoding.EncodeToString(part.Data)
+
+	if strings.HasPrefix(part.MediaType, "image/") {
+		return anthropic.NewImageBlockBase64(part.MediaType, data)
+	}
+
+	var block anthropic.ContentBlockParamUnion
+	if part.IsText() {
+		block = anthropic.NewDocumentBlock(anthropic.PlainTextSourceParam{Data: string(part.Data)})
+	} else {
+		block = anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{Data: data})
+	}
+	if part.Filename != "" {
+		block.OfDocument.Title = anthropic.String(part.Filename)
+	}
+	return block
+}
+
+func ToChatMessages(messages []anthropic.MessageParam) (model.ChatMessages, error) {
+	c := model.ChatMessages{}
+	names := map[string]string{}
+
+	for _, message := range messages {
+		role := string(message.Role)
+		parts := []*model.ChatPart{}
+		for _, content := range message.Content {
+			if content.OfText != nil {
+				parts = append(parts, &model.ChatPart{Type: "text", Text: content.OfText.Text})
+			}
+			if content.OfThinking != nil {
+				parts = append(parts, &model.ChatPart{
+					Type:      "reasoning",
+					Reasoning: content.OfThinking.Thinking,
+					Signature: content.OfThinking.Signature,
+				})
+			}
+			if content.OfRedactedThinking != nil {
+				parts = append(parts, &model.ChatPart{Type: "reasoning", Signature: content.OfRedactedThinking.Data})
+			}
+			if content.OfImage != nil && content.OfImage.Source.OfBase64 != nil {
+				source := content.OfImage.Source.OfBase64
+				data, err := base64.StdEncoding.DecodeString(source.Data)
+				if err != nil {
+					return nil, err
+				}
+				parts = append(parts, &model.ChatPart{Type: "file", MediaType: string(source.MediaType), Data: data})
+			}
+			if content.OfDocument != nil {
+				part := &model.ChatPart{Type: "file", Filename: content.OfDocument.Title.Value}
+				switch source := content.OfDocument.Source; {
+				case source.OfText != nil:
+					part.MediaType = "text/plain"
+					part.Data = []byte(source.OfText.Data)
+				case source.OfBase64 != nil:
+					data, err := base64.StdEncoding.DecodeString(source.OfBase64.Data)
+					if err != nil {
+						return nil, err
+					}
+					part.MediaType = "application/pdf"
+					part.Data = data
+				default:
+					continue
+				}
+				parts = append(parts, part)
+			}
+			if content.OfToolUse != nil {
+				args := map[string]any{}
+				data, err := json.Marshal(content.OfToolUse.Input)
+				if err != nil {
+					return nil, err
+				}
+				if err := json.Unmarshal(data, &args); err != nil {
+					return nil, err
+				}
+
+				names[content.OfToolUse.ID] = content.OfToolUse.Name
+				parts = append(parts, &model.ChatPart{
+					Type:         "tool-call",
+					ToolCallName: content.OfToolUse.Name,
+					ToolCallID:   content.OfToolUse.ID,
+					ToolCallArgs: args,
+				})
+			}
+			if content.OfToolResult != nil {
+				text := []string{}
+				for _, block := range content.OfToolResult.Content {
+					if block.OfText != nil {
+						text = append(text, block.OfText.Text)
+					}
+				}
+
+				role = "tool"
+				id := content.OfToolResult.ToolUseID
+				parts = append(parts, model.NewToolResult(id, names[id], strings.Join(text, "\n")))
+			}
+		}
+
+		c = append(c, &model.ChatMessage{Role: role, Parts: parts})
+	}
+
+	return c, nil
+}
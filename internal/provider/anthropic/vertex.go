@@ -0,0 +1,95 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/TZGyn/kode/internal/provider/vertex"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const vertexVersion = "vertex-2023-10-16"
+
+func init() {
+	provider.Register(models.ProviderVertexAnthropic, NewVertex)
+}
+
+// NewVertex returns a client of the Claude models served by Vertex AI.
+func NewVertex(c *config.Config, m models.Model) (provider.Provider, error) {
+	settings, err := vertex.Resolve(context.Background(), c.Vertex)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := Create(Config{Model: m.APIModel, Options: vertexOptions(settings)})
+	if err != nil {
+		return nil, err
+	}
+
+	client.info = m
+	client.capabilities = provider.CapabilitiesOf(m)
+
+	return client, nil
+}
+
+func vertexOptions(settings vertex.Settings) []option.RequestOption {
+	return []option.RequestOption{
+		option.WithBaseURL(settings.BaseURL),
+		// The Anthropic key is not meant for Google.
+		option.WithHeaderDel("x-api-key"),
+		option.WithMiddleware(vertexMiddleware(settings)),
+	}
+}
+
+// vertexMiddleware routes the Messages API to the model on Vertex AI, which
+// takes the model in the path and the version in the body, and authenticates
+// the request with a token of the credentials.
+func vertexMiddleware(settings vertex.Settings) option.Middleware {
+	return func(r *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		if r.Body != nil && r.Method == http.MethodPost && r.URL.Path == "/v1/messages" {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				return nil, err
+			}
+			r.Body.Close()
+
+			model := gjson.GetBytes(body, "model").String()
+			specifier := "rawPredict"
+			if gjson.GetBytes(body, "stream").Bool() {
+				specifier = "streamRawPredict"
+			}
+
+			body, _ = sjson.DeleteBytes(body, "model")
+			if !gjson.GetBytes(body, "anthropic_version").Exists() {
+				body, _ = sjson.SetBytes(body, "anthropic_version", vertexVersion)
+			}
+
+			r.URL.Path = fmt.Sprintf("/v1/projects/%s/locations/%s/publishers/anthropic/models/%s:%s",
+				settings.Project, settings.Location, model, specifier)
+
+			reader := bytes.NewReader(body)
+			r.Body = io.NopCloser(reader)
+			r.GetBody = func() (io.ReadCloser, error) {
+				_, err := reader.Seek(0, 0)
+				return io.NopCloser(reader), err
+			}
+			r.ContentLength = int64(len(body))
+		}
+
+		token, err := settings.Credentials.Token(r.Context())
+		if err != nil {
+			return nil, fmt.Errorf("vertex: getting a token: %w", err)
+		}
+		r.Header.Set("Authorization", token.Type+" "+token.Value)
+
+		return next(r)
+	}
+}
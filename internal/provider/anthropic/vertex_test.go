@@ -0,0 +1,80 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.google.com/go/auth"
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/TZGyn/kode/internal/provider/vertex"
+)
+
+type staticToken struct{}
+
+func (staticToken) Token(context.Context) (*auth.Token, error) {
+	return &auth.Token{Value: "stub-token", Type: "Bearer"}, nil
+}
+
+func TestVertexMiddleware(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant-not-for-google")
+
+	type captured struct {
+		path string
+		body map[string]any
+		r    *http.Request
+	}
+	requests := make(chan captured, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body := map[string]any{}
+		json.Unmarshal(data, &body)
+		requests <- captured{path: r.URL.Path, body: body, r: r}
+		http.Error(w, `{"type":"error","error":{"type":"invalid_request_error","message":"stop here"}}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	settings := vertex.Settings{
+		Project:     "kode-project",
+		Location:    "us-east5",
+		Credentials: auth.NewCredentials(&auth.CredentialsOptions{TokenProvider: staticToken{}}),
+		BaseURL:     server.URL,
+	}
+	m, _ := models.Find(models.ProviderVertexAnthropic, models.Claude4Sonnet)
+	client, err := Create(Config{Model: m.APIModel, Options: vertexOptions(settings)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.info = m
+
+	messages := model.ChatMessages{{Role: "user", Parts: []*model.ChatPart{{Type: "text", Text: "hi"}}}}
+	if _, err := client.Stream(context.Background(), provider.Request{Messages: messages}, func(provider.Event) {}); err == nil {
+		t.Fatal("Stream succeeded against the stub")
+	}
+
+	request := <-requests
+	want := "/v1/projects/kode-project/locations/us-east5/publishers/anthropic/models/claude-sonnet-4@20250514:streamRawPredict"
+	if request.path != want {
+		t.Errorf("path = %s, want %s", request.path, want)
+	}
+	if _, ok := request.body["model"]; ok {
+		t.Error("the model was left in the body")
+	}
+	if version := request.body["anthropic_version"]; version != vertexVersion {
+		t.Errorf("anthropic_version = %v, want %s", version, vertexVersion)
+	}
+	if request.body["stream"] != true || request.body["messages"] == nil {
+		t.Errorf("body = %v, want the rest of the request kept", request.body)
+	}
+	if authorization := request.r.Header.Get("Authorization"); authorization != "Bearer stub-token" {
+		t.Errorf("Authorization = %q, want Bearer stub-token", authorization)
+	}
+	if request.r.Header.Get("X-Api-Key") != "" {
+		t.Error("the Anthropic API key was sent to Google")
+	}
+}
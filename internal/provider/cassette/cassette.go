@@ -0,0 +1,349 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/aymanbagabas/go-udiff"
+)
+
+type Mode string
+
+const (
+	ModeRecord Mode = "record"
+	ModeReplay Mode = "replay"
+)
+
+const redacted = "REDACTED"
+
+// redactedHeaders hold the credentials of the providers, they are never
+// written to a cassette.
+var redactedHeaders = []string{
+	"Authorization",
+	"X-Api-Key",
+	"Api-Key",
+	"X-Goog-Api-Key",
+	"X-Amz-Security-Token",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// volatile matches what changes from a run to the next in request bodies,
+// the date of the system prompt and the call IDs generated for Gemini. They
+// are ignored when matching requests.
+var volatile = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}|call_[0-9a-f]{24}`)
+
+// Cassette is a recording of HTTP exchanges. Recording appends every
+// exchange once its response has been read and saves the file, replaying
+// serves the exchanges in order to requests that match them.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+
+	mu   sync.Mutex
+	path string
+	mode Mode
+	next int
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers"`
+	Body    Body        `json:"body"`
+}
+
+type Response struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers"`
+	Body    Body        `json:"body"`
+}
+
+// Body is written as text, or base64 for the binary bodies such as the event
+// streams of Bedrock.
+type Body []byte
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string][]byte{"base64": b})
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	text := ""
+	if err := json.Unmarshal(data, &text); err == nil {
+		*b = Body(text)
+		return nil
+	}
+
+	binary := map[string][]byte{}
+	if err := json.Unmarshal(data, &binary); err != nil {
+		return err
+	}
+	*b = binary["base64"]
+	return nil
+}
+
+var (
+	opened   = map[string]*Cassette{}
+	openedMu sync.Mutex
+)
+
+// Open returns the cassette at path, shared by every client of the process so
+// that their exchanges are recorded and replayed in order.
+func Open(path string, mode Mode) (*Cassette, error) {
+	openedMu.Lock()
+	defer openedMu.Unlock()
+
+	if c, ok := opened[path]; ok {
+		if c.mode != mode {
+			return nil, fmt.Errorf("cassette: %s is already open for %s", path, c.mode)
+		}
+		return c, nil
+	}
+
+	c := &Cassette{path: path, mode: mode}
+	switch mode {
+	case ModeRecord:
+	case ModeReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cassette: %w", err)
+		}
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("cassette: %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("cassette: unknown mode %q, expected record or replay", mode)
+	}
+
+	opened[path] = c
+	return c, nil
+}
+
+// Client returns base recording to or replaying the cassette named by
+// KODE_CASSETTE, in the mode of KODE_CASSETTE_MODE, replay by default. base is
+// returned as is when no cassette is set, and may be nil for the default
+// client.
+func Client(base *http.Client) (*http.Client, error) {
+	path := os.Getenv("KODE_CASSETTE")
+	if path == "" {
+		return base, nil
+	}
+
+	mode := Mode(os.Getenv("KODE_CASSETTE_MODE"))
+	if mode == "" {
+		mode = ModeReplay
+	}
+
+	c, err := Open(path, mode)
+	if err != nil {
+		return nil, err
+	}
+	return c.Client(base), nil
+}
+
+// Enabled reports whether a cassette is set.
+func Enabled() bool {
+	return os.Getenv("KODE_CASSETTE") != ""
+}
+
+// Client returns a copy of base whose requests go through the cassette.
+func (c *Cassette) Client(base *http.Client) *http.Client {
+	client := &http.Client{}
+	if base != nil {
+		*client = *base
+	}
+	client.Transport = c.Transport(client.Transport)
+	return client
+}
+
+// Transport returns a RoundTripper recording the exchanges of next, the
+// default transport when nil, or replaying them.
+func (c *Cassette) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{cassette: c, next: next}
+}
+
+type transport struct {
+	cassette *Cassette
+	next     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	body := []byte{}
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	request := Request{
+		Method:  r.Method,
+		URL:     redactURL(r.URL),
+		Headers: redactHeaders(r.Header),
+		Body:    body,
+	}
+
+	if t.cassette.mode == ModeReplay {
+		return t.cassette.replay(r, request)
+	}
+	return t.cassette.record(r, request, t.next)
+}
+
+func (c *Cassette) record(r *http.Request, request Request, next http.RoundTripper) (*http.Response, error) {
+	response, err := next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := &Interaction{
+		Request: request,
+		Response: Response{
+			Status:  response.StatusCode,
+			Headers: redactHeaders(response.Header),
+		},
+	}
+	response.Body = &recording{
+		ReadCloser: response.Body,
+		done: func(body []byte) {
+			interaction.Response.Body = body
+			c.add(interaction)
+		},
+	}
+	return response, nil
+}
+
+// recording reads a response body, the streams being passed on as they
+// arrive, and hands it over once read or closed.
+type recording struct {
+	io.ReadCloser
+	body bytes.Buffer
+	once sync.Once
+	done func(body []byte)
+}
+
+func (r *recording) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.body.Write(p[:n])
+	if err == io.EOF {
+		r.once.Do(func() { r.done(r.body.Bytes()) })
+	}
+	return n, err
+}
+
+func (r *recording) Close() error {
+	r.once.Do(func() { r.done(r.body.Bytes()) })
+	return r.ReadCloser.Close()
+}
+
+func (c *Cassette) add(interaction *Interaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Interactions = append(c.Interactions, interaction)
+
+	// Saving on every exchange keeps what was recorded by a run that is
+	// interrupted.
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err == nil {
+		os.MkdirAll(filepath.Dir(c.path), 0o755)
+		err = os.WriteFile(c.path, data, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cassette:", err)
+	}
+}
+
+func (c *Cassette) replay(r *http.Request, request Request) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.next == len(c.Interactions) {
+		return nil, fmt.Errorf("cassette: %s has no exchange left for %s %s", c.path, request.Method, request.URL)
+	}
+
+	interaction := c.Interactions[c.next]
+	if err := match(interaction.Request, request); err != nil {
+		return nil, fmt.Errorf("cassette: %s: request %d does not match the recording: %w", c.path, c.next+1, err)
+	}
+	c.next++
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Response.Status, http.StatusText(interaction.Response.Status)),
+		StatusCode:    interaction.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        interaction.Response.Headers.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(interaction.Response.Body)),
+		ContentLength: int64(len(interaction.Response.Body)),
+		Request:       r,
+	}, nil
+}
+
+// match compares a request to the recorded one, reporting the difference of
+// their bodies.
+func match(recorded Request, request Request) error {
+	if recorded.Method != request.Method || recorded.URL != request.URL {
+		return fmt.Errorf("recorded %s %s, got %s %s", recorded.Method, recorded.URL, request.Method, request.URL)
+	}
+
+	before, after := normalize(recorded.Body), normalize(request.Body)
+	if before != after {
+		return errors.New("the body changed\n" + udiff.Unified("recorded", "request", before, after))
+	}
+	return nil
+}
+
+// normalize indents JSON bodies, for their differences to show line by line,
+// and blanks their volatile values.
+func normalize(body []byte) string {
+	indented := bytes.Buffer{}
+	if err := json.Indent(&indented, body, "", "  "); err == nil {
+		body = indented.Bytes()
+	}
+	return volatile.ReplaceAllString(string(body), "<volatile>")
+}
+
+func redactHeaders(headers http.Header) http.Header {
+	headers = headers.Clone()
+	for _, name := range redactedHeaders {
+		if headers.Get(name) != "" {
+			headers.Set(name, redacted)
+		}
+	}
+	return headers
+}
+
+// redactURL removes the API keys passed as query parameters.
+func redactURL(u *url.URL) string {
+	redactedURL := *u
+	query := redactedURL.Query()
+	if query.Has("key") {
+		query.Set("key", redacted)
+		redactedURL.RawQuery = query.Encode()
+	}
+	return redactedURL.String()
+}
@@ -0,0 +1,147 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	recorded := Request{Method: "POST", URL: "https://api.example.com/v1/messages", Body: Body(`{"model":"a","system":"Date: 2025-01-02 10:11:12"}`)}
+
+	same := recorded
+	same.Body = Body(`{"model": "a", "system": "Date: 2026-10-18 09:00:00"}`)
+	if err := match(recorded, same); err != nil {
+		t.Errorf("volatile values and formatting: %v", err)
+	}
+
+	otherURL := recorded
+	otherURL.URL = "https://api.example.com/v1/complete"
+	if err := match(recorded, otherURL); err == nil || !strings.Contains(err.Error(), "/v1/complete") {
+		t.Errorf("other URL: %v", err)
+	}
+
+	otherBody := recorded
+	otherBody.Body = Body(`{"model":"b","system":"Date: 2025-01-02 10:11:12"}`)
+	err := match(recorded, otherBody)
+	if err == nil {
+		t.Fatal("a changed body matched")
+	}
+	if !strings.Contains(err.Error(), `-  "model": "a"`) || !strings.Contains(err.Error(), `+  "model": "b"`) {
+		t.Errorf("the error does not show the difference:\n%v", err)
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer sk-secret")
+	headers.Set("X-Api-Key", "sk-ant-secret")
+	headers.Set("Api-Key", "azure-secret")
+	headers.Set("X-Goog-Api-Key", "goog-secret")
+	headers.Set("Content-Type", "application/json")
+
+	redactedHeaders := redactHeaders(headers)
+	for _, name := range []string{"Authorization", "X-Api-Key", "Api-Key", "X-Goog-Api-Key"} {
+		if value := redactedHeaders.Get(name); value != redacted {
+			t.Errorf("%s = %q, want it redacted", name, value)
+		}
+	}
+	if redactedHeaders.Get("Content-Type") != "application/json" {
+		t.Error("Content-Type was redacted")
+	}
+	if headers.Get("Authorization") != "Bearer sk-secret" {
+		t.Error("the headers of the request were modified")
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	u, _ := url.Parse("https://generativelanguage.googleapis.com/v1beta/models/gemini:streamGenerateContent?alt=sse&key=goog-secret")
+
+	redactedURL := redactURL(u)
+	if strings.Contains(redactedURL, "goog-secret") || !strings.Contains(redactedURL, "key="+redacted) || !strings.Contains(redactedURL, "alt=sse") {
+		t.Errorf("redactURL = %s", redactedURL)
+	}
+	if !strings.Contains(u.String(), "goog-secret") {
+		t.Error("the URL of the request was modified")
+	}
+}
+
+func TestBody(t *testing.T) {
+	for name, body := range map[string]Body{
+		"text":   Body(`{"text":"héllo"}`),
+		"binary": Body([]byte{0x00, 0x00, 0x00, 0x7a, 0xff, 0xfe, 0x01}),
+	} {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name == "binary" && !strings.HasPrefix(string(data), `{"base64":`) {
+			t.Errorf("binary body written as %s", data)
+		}
+		if name == "text" && !strings.HasPrefix(string(data), `"`) {
+			t.Errorf("text body written as %s", data)
+		}
+
+		decoded := Body{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, body) {
+			t.Errorf("%s body %v read back as %v", name, body, decoded)
+		}
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write(append([]byte("echo "), body...))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	send := func(c *Cassette, body string) (string, error) {
+		request, _ := http.NewRequest(http.MethodPost, server.URL+"/chat", strings.NewReader(body))
+		request.Header.Set("Authorization", "Bearer sk-secret")
+		response, err := c.Client(nil).Do(request)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+		data, err := io.ReadAll(response.Body)
+		return string(data), err
+	}
+
+	recorder := &Cassette{path: path, mode: ModeRecord}
+	if reply, err := send(recorder, "hello"); err != nil || reply != "echo hello" {
+		t.Fatalf("recording: %q, %v", reply, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-secret") {
+		t.Error("the key was written to the cassette")
+	}
+
+	server.Close()
+	player := &Cassette{path: path, mode: ModeReplay}
+	if err := json.Unmarshal(data, player); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := send(player, "hello"); err != nil || reply != "echo hello" {
+		t.Errorf("replaying: %q, %v", reply, err)
+	}
+	if _, err := send(player, "hello"); err == nil || !strings.Contains(err.Error(), "no exchange left") {
+		t.Errorf("replaying past the end: %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+)
+
+const (
+	defaultMaxRetries   = 2
+	defaultInitialDelay = time.Second
+	defaultMaxDelay     = 30 * time.Second
+)
+
+// Chain is a provider retrying the requests that fail because the model is
+// rate limited, overloaded or unavailable, then handing the conversation over
+// to the fallback models in order, as long as none of the reply was streamed.
+// The model that answered keeps answering the following requests.
+type Chain struct {
+	config *config.Config
+
+	maxRetries   int
+	initialDelay time.Duration
+	maxDelay     time.Duration
+
+	mu     sync.Mutex
+	links  []*link
+	active int
+}
+
+type link struct {
+	name     models.ModelProvider
+	id       models.ModelID
+	provider Provider
+}
+
+// NewChain returns the provider of the model followed by the fallback models
+// of the config. Fallback providers are only created once needed, a model
+// that cannot be created being skipped.
+func NewChain(c *config.Config, name models.ModelProvider, id models.ModelID) (*Chain, error) {
+	primary, err := New(c, name, id)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := &Chain{
+		config:       c,
+		maxRetries:   c.Retry.MaxRetries,
+		initialDelay: defaultInitialDelay,
+		maxDelay:     defaultMaxDelay,
+		links:        []*link{{name: name, id: id, provider: primary}},
+	}
+	if chain.maxRetries == 0 {
+		chain.maxRetries = defaultMaxRetries
+	}
+	if c.Retry.InitialDelay != "" {
+		if chain.initialDelay, err = time.ParseDuration(c.Retry.InitialDelay); err != nil {
+			return nil, fmt.Errorf("retry.initial_delay: %w", err)
+		}
+	}
+	if c.Retry.MaxDelay != "" {
+		if chain.maxDelay, err = time.ParseDuration(c.Retry.MaxDelay); err != nil {
+			return nil, fmt.Errorf("retry.max_delay: %w", err)
+		}
+	}
+
+	for _, fallback := range c.Fallback {
+		if fallback.Provider == name && fallback.Model == id {
+			continue
+		}
+		chain.links = append(chain.links, &link{name: fallback.Provider, id: fallback.Model})
+	}
+
+	return chain, nil
+}
+
+func (c *Chain) Stream(ctx context.Context, request Request, emit EmitFunc) (*model.ChatMessage, error) {
+	c.mu.Lock()
+	active := c.active
+	c.mu.Unlock()
+
+	// cause is the error of the last model that failed, skipped models
+	// aside.
+	var cause error
+	errs := []error{}
+	for i := active; i < len(c.links); i++ {
+		link := c.links[i]
+
+		p, err := c.provider(link)
+		if err != nil {
+			emit(WarningEvent{Message: fmt.Sprintf("skipping %s %s: %s", link.name, link.id, err)})
+			errs = append(errs, err)
+			continue
+		}
+		if i != active {
+			emit(FallbackEvent{Provider: link.name, Model: link.id, Err: cause})
+		}
+
+		reply, streamed, err := c.retry(ctx, link, p, request, emit)
+		if err == nil {
+			c.mu.Lock()
+			c.active = i
+			c.mu.Unlock()
+			return reply, nil
+		}
+
+		cause = err
+		errs = append(errs, fmt.Errorf("%s %s: %w", link.name, link.id, err))
+		if ctx.Err() != nil || !Retryable(err) || streamed {
+			return nil, err
+		}
+	}
+
+	if len(errs) == 1 {
+		return nil, cause
+	}
+	return nil, fmt.Errorf("every model failed: %w", errors.Join(errs...))
+}
+
+// retry sends the request until it succeeds, fails for a reason retrying
+// cannot fix or the retries are exhausted. A request failing once part of the
+// reply was streamed is not retried, as that part cannot be taken back,
+// streamed reports it.
+func (c *Chain) retry(ctx context.Context, link *link, p Provider, request Request, emit EmitFunc) (*model.ChatMessage, bool, error) {
+	streamed := false
+	emitAttempt := func(event Event) {
+		switch event.(type) {
+		case TextDeltaEvent, ToolCallStartEvent:
+			streamed = true
+		}
+		emit(event)
+	}
+
+	for attempt := 0; ; attempt++ {
+		reply, err := p.Stream(ctx, request, emitAttempt)
+		if err == nil || ctx.Err() != nil || !Retryable(err) || streamed || attempt >= c.maxRetries {
+			return reply, streamed, err
+		}
+
+		delay := c.backoff(attempt)
+		if statusErr := (*StatusError)(nil); errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			delay = statusErr.RetryAfter
+		}
+		if delay > c.maxDelay {
+			return nil, false, err
+		}
+
+		emit(WarningEvent{Message: fmt.Sprintf(
+			"%s %s: %s, retrying in %s (%d/%d)", link.name, link.id, err, delay.Round(10*time.Millisecond), attempt+1, c.maxRetries,
+		)})
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, false, ctx.Err()
+		}
+	}
+}
+
+// backoff doubles the delay on every attempt, with some jitter so that
+// clients rate limited together do not retry together.
+func (c *Chain) backoff(attempt int) time.Duration {
+	delay := min(c.initialDelay<<attempt, c.maxDelay)
+	return delay - time.Duration(rand.Int64N(int64(delay)/4+1))
+}
+
+func (c *Chain) provider(link *link) (Provider, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if link.provider == nil {
+		p, err := New(c.config, link.name, link.id)
+		if err != nil {
+			return nil, err
+		}
+		link.provider = p
+	}
+	return link.provider, nil
+}
+
+func (c *Chain) CancelRequest() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	errs := []error{}
+	for _, link := range c.links {
+		if link.provider != nil {
+			errs = append(errs, link.provider.CancelRequest())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Chain) Usage() Usage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	usage := Usage{}
+	for _, link := range c.links {
+		if link.provider != nil {
+			usage.Add(link.provider.Usage())
+		}
+	}
+	return usage
+}
+
+// Active returns the model that answers the requests, the fallback model that
+// took over if any.
+func (c *Chain) Active() (models.ModelProvider, models.ModelID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	link := c.links[c.active]
+	return link.name, link.id
+}
+
+// Capabilities are the ones of the model that answers.
+func (c *Chain) Capabilities() Capabilities {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.links[c.active].provider.Capabilities()
+}
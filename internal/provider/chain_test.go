@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TZGyn/kode/internal/model"
+)
+
+// attempt is the outcome of a request to a stub: the text it streams, then
+// the error it fails with.
+type attempt struct {
+	text string
+	err  error
+}
+
+type stub struct {
+	attempts []attempt
+	calls    int
+}
+
+func (s *stub) Stream(ctx context.Context, request Request, emit EmitFunc) (*model.ChatMessage, error) {
+	a := s.attempts[s.calls]
+	s.calls++
+
+	if a.text != "" {
+		emit(TextDeltaEvent{Text: a.text})
+	}
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &model.ChatMessage{Role: "assistant", Parts: []*model.ChatPart{{Type: "text", Text: a.text}}}, nil
+}
+
+func (s *stub) CancelRequest() error       { return nil }
+func (s *stub) Usage() Usage               { return Usage{} }
+func (s *stub) Capabilities() Capabilities { return Capabilities{} }
+
+func testChain(providers ...Provider) *Chain {
+	chain := &Chain{maxRetries: 2, initialDelay: time.Millisecond, maxDelay: time.Second}
+	for _, p := range providers {
+		chain.links = append(chain.links, &link{name: "stub", id: "stub", provider: p})
+	}
+	return chain
+}
+
+func streamText(t *testing.T, chain *Chain) (string, error) {
+	t.Helper()
+
+	text := ""
+	_, err := chain.Stream(context.Background(), Request{}, func(event Event) {
+		if delta, ok := event.(TextDeltaEvent); ok {
+			text += delta.Text
+		}
+	})
+	return text, err
+}
+
+var overloaded = &StatusError{StatusCode: 529, Err: errors.New("overloaded")}
+
+func TestChainRetries(t *testing.T) {
+	primary := &stub{attempts: []attempt{{err: overloaded}, {err: overloaded}, {text: "hello"}}}
+
+	text, err := streamText(t, testChain(primary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "hello" || primary.calls != 3 {
+		t.Errorf("streamed %q in %d calls, want hello in 3", text, primary.calls)
+	}
+}
+
+func TestChainFallsBack(t *testing.T) {
+	primary := &stub{attempts: []attempt{{err: overloaded}, {err: overloaded}, {err: overloaded}}}
+	fallback := &stub{attempts: []attempt{{text: "hello"}, {text: "again"}}}
+	chain := testChain(primary, fallback)
+	chain.links[1].id = "fallback"
+
+	if name, id := chain.Active(); id != "stub" {
+		t.Errorf("Active() = %s %s before falling back, want the primary model", name, id)
+	}
+	if text, err := streamText(t, chain); err != nil || text != "hello" {
+		t.Fatalf("streamed %q, %v", text, err)
+	}
+	if name, id := chain.Active(); id != "fallback" {
+		t.Errorf("Active() = %s %s, want the fallback model", name, id)
+	}
+	if text, err := streamText(t, chain); err != nil || text != "again" {
+		t.Fatalf("streamed %q, %v, want the fallback to keep answering", text, err)
+	}
+	if primary.calls != 3 {
+		t.Errorf("primary called %d times, want 3", primary.calls)
+	}
+}
+
+func TestChainDoesNotRetryStreamedReplies(t *testing.T) {
+	primary := &stub{attempts: []attempt{{text: "hel", err: overloaded}, {text: "hello"}}}
+	fallback := &stub{attempts: []attempt{{text: "hello"}}}
+
+	text, err := streamText(t, testChain(primary, fallback))
+	if !errors.Is(err, overloaded) {
+		t.Errorf("err = %v, want the overloaded error", err)
+	}
+	if text != "hel" || primary.calls != 1 || fallback.calls != 0 {
+		t.Errorf("streamed %q in %d+%d calls, want hel in a single call", text, primary.calls, fallback.calls)
+	}
+}
+
+func TestChainDoesNotRetryPermanentErrors(t *testing.T) {
+	invalid := &StatusError{StatusCode: 400, Err: errors.New("invalid request")}
+	primary := &stub{attempts: []attempt{{err: invalid}}}
+	fallback := &stub{attempts: []attempt{{text: "hello"}}}
+
+	if _, err := streamText(t, testChain(primary, fallback)); !errors.Is(err, invalid) {
+		t.Errorf("err = %v, want the invalid request error", err)
+	}
+	if fallback.calls != 0 {
+		t.Error("fell back on a permanent error")
+	}
+}
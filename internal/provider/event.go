@@ -0,0 +1,72 @@
+package provider
+
+import "github.com/TZGyn/kode/internal/models"
+
+// Event is a streaming update emitted by a provider while a turn is running.
+// Events are forwarded to the TUI as tea messages.
+type Event any
+
+type TextDeltaEvent struct {
+	Text string
+}
+
+type ToolCallStartEvent struct {
+	ID   string
+	Name string
+}
+
+// ToolCallEvent is emitted once the arguments of a tool call are known, right
+// before it is executed.
+type ToolCallEvent struct {
+	ID   string
+	Name string
+	Args map[string]any
+}
+
+// ToolCallEndEvent is emitted once a tool call has been executed. Output is
+// the markdown rendering of the call meant for the transcript. Err is set when
+// the call failed, rejected calls aside.
+type ToolCallEndEvent struct {
+	ID     string
+	Name   string
+	Args   map[string]any
+	Result string
+	Output string
+	Err    error
+}
+
+// UsageEvent reports the usage of a single provider request.
+type UsageEvent struct {
+	Usage Usage
+}
+
+// StepEvent is emitted by the agent loop before each model request. Tools
+// are the tools called by the previous step.
+type StepEvent struct {
+	Step     int
+	MaxSteps int
+	Tools    []string
+}
+
+// CompactionEvent is emitted once older turns have been summarized to make
+// room in the context window, with the estimated size of the history.
+type CompactionEvent struct {
+	Before int64
+	After  int64
+}
+
+// FallbackEvent is emitted when a model of the fallback chain takes over the
+// conversation, the previous one having failed with Err.
+type FallbackEvent struct {
+	Provider models.ModelProvider
+	Model    models.ModelID
+	Err      error
+}
+
+// WarningEvent reports a condition the user should know about without
+// stopping the turn, such as a budget nearing its limit.
+type WarningEvent struct {
+	Message string
+}
+
+type EmitFunc func(event Event)
@@ -2,8 +2,10 @@ package google
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/TZGyn/kode/internal/tool"
 	"google.golang.org/genai"
 )
 
@@ -33,103 +35,39 @@ var googleConfig = &genai.GenerateContentConfig{
 
 var tools = []*genai.Tool{
 	{
-		FunctionDeclarations: []*genai.FunctionDeclaration{
-			{
-				Name:        "list_directory",
-				Description: "Given a directory, return all the children of it",
-				Parameters: &genai.Schema{
-					Type: "object",
-					Properties: map[string]*genai.Schema{
-						"directory": {
-							Type:        "string",
-							Description: "the directory to output, use . for root",
-						},
-					},
-				},
-				Response: &genai.Schema{
-					Type: "object",
-					Properties: map[string]*genai.Schema{
-						"result": {
-							Type:        "string",
-							Description: "children as list",
-							// Items: &genai.Schema{
-							// 	Type:        "string",
-							// 	Description: "children, folder or file",
-							// },
-						},
-					},
-				},
-			},
-			{
-				Name:        "cat_file",
-				Description: "Given a file path, return all its content as string",
-				Parameters: &genai.Schema{
-					Type: "object",
-					Properties: map[string]*genai.Schema{
-						"filePath": {
-							Type:        "string",
-							Description: "the file path to output relative to root",
-						},
-					},
-				},
-				Response: &genai.Schema{
-					Type: "object",
-					Properties: map[string]*genai.Schema{
-						"result": {
-							Type:        "string",
-							Description: "file content",
-						},
-					},
-				},
-			},
-			{
-				Name:        "create_file",
-				Description: "Given a file path, create a empty file in the path",
-				Parameters: &genai.Schema{
-					Type: "object",
-					Properties: map[string]*genai.Schema{
-						"filePath": {
-							Type:        "string",
-							Description: "the file path to create relative to root",
-						},
-					},
-				},
-				Response: &genai.Schema{
-					Type: "object",
-					Properties: map[string]*genai.Schema{
-						"result": {
-							Type:        "string",
-							Description: "file create result, either file created successfully or an error message",
-						},
-					},
-				},
-			},
-			{
-				Name:        "update_file",
-				Description: "Update file, given the complete new file content",
-				Parameters: &genai.Schema{
-					Type: "object",
-					Properties: map[string]*genai.Schema{
-						"path": {
-							Type:        "string",
-							Description: "Path of the file",
-						},
-						"new_content": {
-							Type:        "string",
-							Description: "New file content",
-						},
-					},
-				},
-				Response: &genai.Schema{
-					Type: "object",
-					Properties: map[string]*genai.Schema{
-						"result": {
-							Type:        "string",
-							Description: "file update result, either file updated successfully or an error message",
-						},
-					},
-				},
-			},
-		},
+		FunctionDeclarations: functionDeclarations(tool.Default),
 	},
 }
+
+func functionDeclarations(registry *tool.Registry) []*genai.FunctionDeclaration {
+	declarations := []*genai.FunctionDeclaration{}
+	for _, t := range registry.Tools() {
+		declarations = append(declarations, &genai.FunctionDeclaration{
+			Name:        t.Name(),
+			Description: t.Description(),
+			Parameters:  schema(t.Parameters()),
+		})
+	}
+	return declarations
+}
+
+func schema(s *tool.Schema) *genai.Schema {
+	if s == nil {
+		return nil
+	}
+
+	result := &genai.Schema{
+		Type:        genai.Type(strings.ToUpper(s.Type)),
+		Description: s.Description,
+		Items:       schema(s.Items),
+		Enum:        s.Enum,
+		Required:    s.Required,
+	}
+	if len(s.Properties) > 0 {
+		result.Properties = map[string]*genai.Schema{}
+		for name, property := range s.Properties {
+			result.Properties[name] = schema(property)
+		}
+	}
+	return result
+}
@@ -2,15 +2,26 @@ package google
 
 import (
 	"context"
-	"time"
-
-	"github.com/TZGyn/kode/internal/tool"
+	"errors"
+	"net/http"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/TZGyn/kode/internal/provider/cassette"
+	"github.com/TZGyn/kode/internal/provider/vertex"
 	"google.golang.org/genai"
 )
 
 type Config struct {
 	GEMINI_API_KEY string `json:"GEMINI_API_KEY"`
 	Model          string `json:"model"`
+	// Vertex switches the client to Vertex AI, the API key being unused.
+	Vertex *vertex.Settings `json:"-"`
+	// HTTPClient sends the requests, through the cassette set in the
+	// environment if any.
+	HTTPClient *http.Client `json:"-"`
 }
 
 func DefaultConfig(apiKey string, model string) Config {
@@ -24,20 +35,86 @@ type GoogleClient struct {
 	context       context.Context
 	cancelRequest context.CancelFunc
 
-	model string
+	model   string
+	backend genai.Backend
+
+	info         models.Model
+	capabilities provider.Capabilities
+	usage        provider.Usage
 
-	client        *genai.Client
-	Messages      []*genai.Content
-	FunctionCalls []string
+	client *genai.Client
+}
+
+func init() {
+	provider.Register(models.ProviderGemini, New)
+	provider.Register(models.ProviderVertex, NewVertex)
+}
+
+func New(c *config.Config, m models.Model) (provider.Provider, error) {
+	client, err := CreateGoogle(DefaultConfig(c.GEMINI_API_KEY, m.APIModel))
+	if err != nil {
+		return nil, err
+	}
+
+	client.info = m
+	client.capabilities = provider.CapabilitiesOf(m)
+
+	return client, nil
+}
+
+// NewVertex returns a client of the Gemini models served by Vertex AI.
+func NewVertex(c *config.Config, m models.Model) (provider.Provider, error) {
+	settings, err := vertex.Resolve(context.Background(), c.Vertex)
+	if err != nil {
+		return nil, err
+	}
+
+	config := DefaultConfig("", m.APIModel)
+	config.Vertex = &settings
+
+	client, err := CreateGoogle(config)
+	if err != nil {
+		return nil, err
+	}
+
+	client.info = m
+	client.capabilities = provider.CapabilitiesOf(m)
+
+	return client, nil
 }
 
 func CreateGoogle(config Config) (*GoogleClient, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	base := config.HTTPClient
+	// genai only authenticates to Vertex AI the clients it creates.
+	if base == nil && config.Vertex != nil && cassette.Enabled() {
+		var err error
+		if base, err = config.Vertex.HTTPClient(); err != nil {
+			return nil, err
+		}
+	}
+	httpClient, err := cassette.Client(base)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
 
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+	clientConfig := &genai.ClientConfig{
 		APIKey:  config.GEMINI_API_KEY,
 		Backend: genai.BackendGeminiAPI,
-	})
+	}
+	if config.Vertex != nil {
+		clientConfig = &genai.ClientConfig{
+			Backend:     genai.BackendVertexAI,
+			Project:     config.Vertex.Project,
+			Location:    config.Vertex.Location,
+			Credentials: config.Vertex.Credentials,
+			HTTPOptions: genai.HTTPOptions{BaseURL: config.Vertex.BaseURL},
+		}
+	}
+	clientConfig.HTTPClient = httpClient
+
+	client, err := genai.NewClient(ctx, clientConfig)
 	if err != nil {
 		cancel()
 		return nil, err
@@ -47,65 +124,107 @@ func CreateGoogle(config Config) (*GoogleClient, error) {
 		context:       ctx,
 		cancelRequest: cancel,
 
-		model: config.Model,
+		model:   config.Model,
+		backend: clientConfig.Backend,
 
-		client:        client,
-		Messages:      []*genai.Content{},
-		FunctionCalls: []string{},
+		client: client,
 	}, nil
 }
 
-func (c *GoogleClient) SendMessage(messages []*genai.Content, response *string) error {
-	content, err := c.client.Models.GenerateContent(
-		c.context,
-		c.model,
-		messages,
-		googleConfig,
-	)
+func (c *GoogleClient) Stream(ctx context.Context, request provider.Request, emit provider.EmitFunc) (*model.ChatMessage, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(c.context, cancel)()
+
+	messages, err := FromChatMessages(request.Messages, c.backend)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	config := googleConfig
+	if request.NoTools {
+		noTools := *googleConfig
+		noTools.ToolConfig = &genai.ToolConfig{
+			FunctionCallingConfig: &genai.FunctionCallingConfig{Mode: genai.FunctionCallingConfigModeNone},
+		}
+		config = &noTools
 	}
 
-	text := ""
+	text, thoughts := "", ""
+	functionCalls := []*genai.FunctionCall{}
+	var usageMetadata *genai.GenerateContentResponseUsageMetadata
+
+	for content, err := range c.client.Models.GenerateContentStream(ctx, c.model, messages, config) {
+		if err != nil {
+			return nil, statusError(err)
+		}
+
+		if content.UsageMetadata != nil {
+			usageMetadata = content.UsageMetadata
+		}
+
+		if len(content.Candidates) == 0 || content.Candidates[0].Content == nil {
+			continue
+		}
 
-	for _, part := range content.Candidates[0].Content.Parts {
-		if part.Text != "" {
-			if part.Thought {
-				continue
+		for _, part := range content.Candidates[0].Content.Parts {
+			if part.Text != "" && part.Thought {
+				thoughts += part.Text
+			}
+			if part.Text != "" && !part.Thought {
+				text += part.Text
+				emit(provider.TextDeltaEvent{Text: part.Text})
+			}
+			if part.FunctionCall != nil {
+				functionCalls = append(functionCalls, part.FunctionCall)
+				emit(provider.ToolCallStartEvent{ID: part.FunctionCall.ID, Name: part.FunctionCall.Name})
 			}
-			*response += part.Text
-			text += part.Text
 		}
 	}
 
-	c.Messages = append(c.Messages, &genai.Content{Role: "assistant", Parts: []*genai.Part{{Text: text}}})
+	if usageMetadata != nil {
+		// Thoughts are billed as output but not counted in the candidates.
+		usage := provider.Usage{
+			InputTokens:     int64(usageMetadata.PromptTokenCount - usageMetadata.CachedContentTokenCount),
+			OutputTokens:    int64(usageMetadata.CandidatesTokenCount + usageMetadata.ThoughtsTokenCount),
+			CacheReadTokens: int64(usageMetadata.CachedContentTokenCount),
+			ReasoningTokens: int64(usageMetadata.ThoughtsTokenCount),
+		}.Priced(c.info)
+		c.usage.Add(usage)
+		emit(provider.UsageEvent{Usage: usage})
+	}
+
+	if text == "" && len(functionCalls) == 0 {
+		return nil, errors.New("empty response")
+	}
 
-	if len(content.FunctionCalls()) == 0 {
-		return nil
+	parts := []*genai.Part{}
+	if thoughts != "" {
+		parts = append(parts, &genai.Part{Text: thoughts, Thought: true})
+	}
+	if text != "" {
+		parts = append(parts, &genai.Part{Text: text})
+	}
+	for _, functionCall := range functionCalls {
+		parts = append(parts, &genai.Part{FunctionCall: functionCall})
 	}
 
-	for _, functionCall := range content.FunctionCalls() {
-		result, err := tool.HandleTool(functionCall.Name, functionCall.Args, response)
-		if err != nil {
-			continue
-		}
+	reply, err := ToChatMessages([]*genai.Content{{Role: "model", Parts: parts}})
+	if err != nil {
+		return nil, err
+	}
+	reply[0].Provider, reply[0].Model = c.info.Provider, c.info.ID
+	return reply[0], nil
+}
 
-		messages = append(messages, &genai.Content{
-			Role: "tool",
-			Parts: []*genai.Part{
-				{
-					FunctionResponse: &genai.FunctionResponse{
-						ID:   functionCall.ID,
-						Name: functionCall.Name,
-						Response: map[string]any{
-							"result": result,
-						},
-					},
-				},
-			}})
-	}
-
-	return c.SendMessage(c.Messages, response)
+// statusError exposes the status of API errors, for them to be retried. genai
+// does not expose the headers of the response.
+func statusError(err error) error {
+	apiErr := genai.APIError{}
+	if errors.As(err, &apiErr) {
+		return provider.NewStatusError(apiErr.Code, nil, err)
+	}
+	return err
 }
 
 func (c *GoogleClient) CancelRequest() error {
@@ -114,3 +233,11 @@ func (c *GoogleClient) CancelRequest() error {
 	}
 	return nil
 }
+
+func (c *GoogleClient) Usage() provider.Usage {
+	return c.usage
+}
+
+func (c *GoogleClient) Capabilities() provider.Capabilities {
+	return c.capabilities
+}
@@ -0,0 +1,150 @@
+package google
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+	"google.golang.org/genai"
+)
+
+// FromChatMessages converts the history for the backend, the Gemini API
+// rejecting the display name of attachments.
+func FromChatMessages(c model.ChatMessages, backend genai.Backend) ([]*genai.Content, error) {
+	googleMessages := []*genai.Content{}
+
+	for _, content := range c {
+		parts := []*genai.Part{}
+
+		for _, part := range content.Parts {
+			if part.Type == "text" && part.Text != "" {
+				parts = append(parts, &genai.Part{
+					Text: part.Text,
+				})
+			}
+			if part.Type == "reasoning" && part.Reasoning != "" &&
+				(content.Provider == models.ProviderGemini || content.Provider == models.ProviderVertex) {
+				parts = append(parts, &genai.Part{
+					Text:    part.Reasoning,
+					Thought: true,
+				})
+			}
+			if part.Type == "file" {
+				blob := &genai.Blob{
+					MIMEType: part.MediaType,
+					Data:     part.Data,
+				}
+				if backend == genai.BackendVertexAI {
+					blob.DisplayName = part.Filename
+				}
+				parts = append(parts, &genai.Part{InlineData: blob})
+			}
+			if part.Type == "tool-call" {
+				parts = append(parts, &genai.Part{
+					FunctionCall: &genai.FunctionCall{
+						Name: part.ToolCallName,
+						ID:   part.ToolCallID,
+						Args: part.ToolCallArgs,
+					},
+				})
+			}
+			if part.Type == "tool-result" {
+				parts = append(parts, &genai.Part{
+					FunctionResponse: &genai.FunctionResponse{
+						Name:     part.ToolCallName,
+						ID:       part.ToolCallID,
+						Response: part.ToolCallResult,
+					},
+				})
+			}
+		}
+
+		if len(parts) == 0 {
+			continue
+		}
+
+		// Function responses are sent back by the user.
+		role := "user"
+		if content.Role == "assistant" {
+			role = "model"
+		}
+
+		googleMessages = append(googleMessages, &genai.Content{
+			Role:  role,
+			Parts: parts,
+		})
+	}
+
+	return googleMessages, nil
+}
+
+// ToChatMessages converts the history back. Gemini can leave function calls
+// without an id, they are given one so that their results can be paired with
+// them by the providers that require it.
+func ToChatMessages(messages []*genai.Content) (model.ChatMessages, error) {
+	c := model.ChatMessages{}
+
+	for _, content := range messages {
+		role := "assistant"
+		if content.Role == "user" {
+			role = "user"
+		}
+
+		parts := []*model.ChatPart{}
+
+		for _, part := range content.Parts {
+			if len(part.Text) > 0 && part.Thought {
+				parts = append(parts, &model.ChatPart{
+					Type:      "reasoning",
+					Reasoning: part.Text,
+				})
+			}
+			if len(part.Text) > 0 && !part.Thought {
+				parts = append(parts, &model.ChatPart{
+					Type: "text",
+					Text: part.Text,
+				})
+			}
+			if part.InlineData != nil {
+				parts = append(parts, &model.ChatPart{
+					Type:      "file",
+					Filename:  part.InlineData.DisplayName,
+					MediaType: part.InlineData.MIMEType,
+					Data:      part.InlineData.Data,
+				})
+			}
+			if part.FunctionCall != nil {
+				id := part.FunctionCall.ID
+				if id == "" {
+					id = callID()
+				}
+				parts = append(parts, &model.ChatPart{
+					Type:         "tool-call",
+					ToolCallName: part.FunctionCall.Name,
+					ToolCallID:   id,
+					ToolCallArgs: part.FunctionCall.Args,
+				})
+			}
+			if part.FunctionResponse != nil {
+				role = "tool"
+				parts = append(parts, &model.ChatPart{
+					Type:           "tool-result",
+					ToolCallName:   part.FunctionResponse.Name,
+					ToolCallID:     part.FunctionResponse.ID,
+					ToolCallResult: part.FunctionResponse.Response,
+				})
+			}
+		}
+
+		c = append(c, &model.ChatMessage{Role: role, Parts: parts})
+	}
+
+	return c, nil
+}
+
+func callID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "call_" + hex.EncodeToString(b)
+}
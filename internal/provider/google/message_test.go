@@ -0,0 +1,84 @@
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/provider"
+	"google.golang.org/genai"
+)
+
+// attachment is a prompt with a file attached.
+var attachment = model.ChatMessages{{Role: "user", Parts: []*model.ChatPart{
+	{Type: "text", Text: "What is in this file?"},
+	{Type: "file", Filename: "notes.txt", MediaType: "text/plain", Data: []byte("hello")},
+}}}
+
+func TestFromChatMessagesFile(t *testing.T) {
+	for backend, want := range map[genai.Backend]string{
+		genai.BackendGeminiAPI: "",
+		genai.BackendVertexAI:  "notes.txt",
+	} {
+		messages, err := FromChatMessages(attachment, backend)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blob := messages[0].Parts[1].InlineData
+		if blob == nil || string(blob.Data) != "hello" || blob.MIMEType != "text/plain" {
+			t.Fatalf("%s: file part = %+v", backend, messages[0].Parts[1])
+		}
+		if blob.DisplayName != want {
+			t.Errorf("%s: display name = %q, want %q", backend, blob.DisplayName, want)
+		}
+	}
+}
+
+// TestStreamFileGeminiAPI sends an attachment through the request conversion
+// of the Gemini API, which rejects the fields it does not support.
+func TestStreamFileGeminiAPI(t *testing.T) {
+	bodies := make(chan map[string]any, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]any{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		bodies <- body
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"candidates":[{"content":{"role":"model","parts":[{"text":"It says hello."}]}}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":4}}`+"\n\n")
+	}))
+	defer server.Close()
+	t.Setenv("GOOGLE_GEMINI_BASE_URL", server.URL)
+
+	client, err := CreateGoogle(DefaultConfig("key", "gemini-2.5-flash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := ""
+	_, err = client.Stream(context.Background(), provider.Request{Messages: attachment}, func(event provider.Event) {
+		if event, ok := event.(provider.TextDeltaEvent); ok {
+			text += event.Text
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "It says hello." {
+		t.Errorf("streamed %q", text)
+	}
+
+	contents := (<-bodies)["contents"].([]any)
+	parts := contents[0].(map[string]any)["parts"].([]any)
+	inline, ok := parts[1].(map[string]any)["inlineData"].(map[string]any)
+	if !ok {
+		t.Fatalf("file part = %v, want inlineData", parts[1])
+	}
+	if _, ok := inline["displayName"]; ok {
+		t.Errorf("inlineData = %v, sent with a display name", inline)
+	}
+}
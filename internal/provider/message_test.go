@@ -0,0 +1,133 @@
+package provider_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/provider/anthropic"
+	"github.com/TZGyn/kode/internal/provider/google"
+	"github.com/TZGyn/kode/internal/provider/openai"
+	"google.golang.org/genai"
+)
+
+// history is a turn calling two tools in parallel, then another one.
+func history() model.ChatMessages {
+	return model.ChatMessages{
+		{Role: "user", Parts: []*model.ChatPart{{Type: "text", Text: "What is in main.go and go.mod?"}}},
+		{Role: "assistant", Parts: []*model.ChatPart{
+			{Type: "text", Text: "Let me look."},
+			{Type: "tool-call", ToolCallID: "call_1", ToolCallName: "cat_file", ToolCallArgs: map[string]any{"filePath": "main.go"}},
+			{Type: "tool-call", ToolCallID: "call_2", ToolCallName: "cat_file", ToolCallArgs: map[string]any{"filePath": "go.mod"}},
+		}},
+		{Role: "tool", Parts: []*model.ChatPart{
+			model.NewToolResult("call_1", "cat_file", "package main"),
+			model.NewToolResult("call_2", "cat_file", "module example"),
+		}},
+		{Role: "assistant", Parts: []*model.ChatPart{
+			{Type: "tool-call", ToolCallID: "call_3", ToolCallName: "list_directory", ToolCallArgs: map[string]any{"path": "."}},
+		}},
+		{Role: "tool", Parts: []*model.ChatPart{
+			model.NewToolResult("call_3", "list_directory", "main.go\ngo.mod"),
+		}},
+		{Role: "assistant", Parts: []*model.ChatPart{{Type: "text", Text: "A main package and its module."}}},
+	}
+}
+
+func throughAnthropic(t *testing.T, c model.ChatMessages) model.ChatMessages {
+	t.Helper()
+
+	messages, err := anthropic.FromChatMessages(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = anthropic.ToChatMessages(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func throughOpenAI(t *testing.T, c model.ChatMessages) model.ChatMessages {
+	t.Helper()
+
+	messages, err := openai.FromChatMessages(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = openai.ToChatMessages(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func throughGemini(t *testing.T, c model.ChatMessages) model.ChatMessages {
+	t.Helper()
+
+	messages, err := google.FromChatMessages(c, genai.BackendVertexAI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = google.ToChatMessages(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// TestRoundTrip hands the history over from Anthropic to OpenAI to Gemini and
+// back, as fallbacks and model switches do, and expects it unchanged.
+func TestRoundTrip(t *testing.T) {
+	want := history()
+
+	got := throughAnthropic(t, history())
+	got = throughOpenAI(t, got)
+	got = throughGemini(t, got)
+	got = throughAnthropic(t, got)
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Role != want[i].Role {
+			t.Errorf("message %d: role %s, want %s", i, got[i].Role, want[i].Role)
+		}
+		if len(got[i].Parts) != len(want[i].Parts) {
+			t.Errorf("message %d: %d parts, want %d", i, len(got[i].Parts), len(want[i].Parts))
+			continue
+		}
+		for j, part := range want[i].Parts {
+			if !reflect.DeepEqual(got[i].Parts[j], part) {
+				t.Errorf("message %d part %d: %+v, want %+v", i, j, got[i].Parts[j], part)
+			}
+		}
+	}
+}
+
+// TestToolResultsFollowTheirCalls checks every result of the round trip
+// answers a call of the previous assistant message, in the order of calls.
+func TestToolResultsFollowTheirCalls(t *testing.T) {
+	c := throughGemini(t, throughOpenAI(t, throughAnthropic(t, history())))
+
+	calls := []string{}
+	for _, message := range c {
+		switch message.Role {
+		case "assistant":
+			calls = calls[:0]
+			for _, part := range message.Parts {
+				if part.Type == "tool-call" {
+					calls = append(calls, part.ToolCallID)
+				}
+			}
+		case "tool":
+			results := []string{}
+			for _, part := range message.Parts {
+				results = append(results, part.ToolCallID)
+			}
+			if !reflect.DeepEqual(results, calls) {
+				t.Errorf("results %v do not answer the calls %v", results, calls)
+			}
+		}
+	}
+}
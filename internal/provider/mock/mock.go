@@ -0,0 +1,318 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/TZGyn/kode/internal/tokens"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrScriptExhausted is returned once every response of a script that does not
+// loop has been replayed.
+var ErrScriptExhausted = errors.New("mock: script exhausted")
+
+// Script is a fixture of responses, replayed one per request. A fixture is
+// written in YAML or JSON:
+//
+//	chunk_delay: 20ms
+//	responses:
+//	  - text: Let me look at the file.
+//	    tool_calls:
+//	      - name: cat_file
+//	        args: {filePath: main.go}
+//	  - delay: 1s
+//	    reasoning: The file is fine.
+//	    text: Nothing to change.
+//	  - error: overloaded
+//	    status: 529
+//
+// Loop replays the responses from the start once they are exhausted.
+// ChunkDelay is waited between the streamed words of a text.
+type Script struct {
+	Responses  []Response `yaml:"responses" json:"responses"`
+	Loop       bool       `yaml:"loop" json:"loop"`
+	ChunkDelay Duration   `yaml:"chunk_delay" json:"chunk_delay"`
+}
+
+// Response is a scripted answer. Delay is waited before answering, Error
+// fails the request instead, as an API error of Status asking to retry after
+// RetryAfter when Status is set. Tool calls are dropped from requests that
+// forbid tools.
+type Response struct {
+	Delay      Duration   `yaml:"delay" json:"delay"`
+	Reasoning  string     `yaml:"reasoning" json:"reasoning"`
+	Text       string     `yaml:"text" json:"text"`
+	ToolCalls  []ToolCall `yaml:"tool_calls" json:"tool_calls"`
+	Error      string     `yaml:"error" json:"error"`
+	Status     int        `yaml:"status" json:"status"`
+	RetryAfter Duration   `yaml:"retry_after" json:"retry_after"`
+}
+
+type ToolCall struct {
+	Name string         `yaml:"name" json:"name"`
+	Args map[string]any `yaml:"args" json:"args"`
+}
+
+// Duration is a time.Duration written as in "1.5s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var value string
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+type Config struct {
+	// Script is the path of the fixture, the model echoes the last user
+	// message without one.
+	Script string `json:"script"`
+}
+
+type MockClient struct {
+	context       context.Context
+	cancelRequest context.CancelFunc
+
+	mu     sync.Mutex
+	script *Script
+	next   int
+	calls  int
+
+	info         models.Model
+	capabilities provider.Capabilities
+	usage        provider.Usage
+}
+
+func init() {
+	provider.Register(models.ProviderMock, New)
+}
+
+func DefaultConfig(c *config.Config) Config {
+	script := c.Mock.Script
+	if script == "" {
+		script = os.Getenv("KODE_MOCK_SCRIPT")
+	}
+	return Config{Script: script}
+}
+
+func New(c *config.Config, m models.Model) (provider.Provider, error) {
+	client, err := Create(DefaultConfig(c))
+	if err != nil {
+		return nil, err
+	}
+
+	client.info = m
+	client.capabilities = provider.CapabilitiesOf(m)
+
+	return client, nil
+}
+
+func Create(config Config) (*MockClient, error) {
+	var script *Script
+	if config.Script != "" {
+		loaded, err := Load(config.Script)
+		if err != nil {
+			return nil, err
+		}
+		script = loaded
+	}
+
+	return FromScript(script), nil
+}
+
+// FromScript returns a client replaying script, or echoing when it is nil.
+func FromScript(script *Script) *MockClient {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	info, _ := models.Find(models.ProviderMock, models.Mock)
+	return &MockClient{
+		context:       ctx,
+		cancelRequest: cancel,
+
+		script: script,
+
+		info:         info,
+		capabilities: provider.CapabilitiesOf(info),
+	}
+}
+
+// Load reads a fixture, YAML being a superset of JSON.
+func Load(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	script := &Script{}
+	if err := yaml.Unmarshal(data, script); err != nil {
+		return nil, fmt.Errorf("mock: %s: %w", path, err)
+	}
+	if len(script.Responses) == 0 {
+		return nil, fmt.Errorf("mock: %s has no responses", path)
+	}
+	return script, nil
+}
+
+func (c *MockClient) Stream(ctx context.Context, request provider.Request, emit provider.EmitFunc) (*model.ChatMessage, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(c.context, cancel)()
+
+	response, err := c.respond(request.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := wait(ctx, time.Duration(response.Delay)); err != nil {
+		return nil, err
+	}
+	if response.Error != "" && response.Status != 0 {
+		return nil, &provider.StatusError{
+			StatusCode: response.Status,
+			RetryAfter: time.Duration(response.RetryAfter),
+			Err:        fmt.Errorf("mock: %d %s", response.Status, response.Error),
+		}
+	}
+	if response.Error != "" {
+		return nil, errors.New("mock: " + response.Error)
+	}
+
+	reply := &model.ChatMessage{Role: "assistant", Provider: c.info.Provider, Model: c.info.ID}
+	if response.Reasoning != "" {
+		reply.Parts = append(reply.Parts, &model.ChatPart{Type: "reasoning", Reasoning: response.Reasoning})
+	}
+
+	if response.Text != "" {
+		reply.Parts = append(reply.Parts, &model.ChatPart{Type: "text", Text: response.Text})
+		for _, word := range strings.SplitAfter(response.Text, " ") {
+			emit(provider.TextDeltaEvent{Text: word})
+			if err := wait(ctx, c.chunkDelay()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if c.capabilities.Tools && !request.NoTools {
+		for _, call := range response.ToolCalls {
+			id := c.callID()
+			reply.Parts = append(reply.Parts, &model.ChatPart{
+				Type:         "tool-call",
+				ToolCallName: call.Name,
+				ToolCallID:   id,
+				ToolCallArgs: call.Args,
+			})
+			emit(provider.ToolCallStartEvent{ID: id, Name: call.Name})
+		}
+	}
+
+	if len(reply.Parts) == 0 {
+		return nil, errors.New("empty response")
+	}
+
+	counter := tokens.ForModel(c.info)
+	usage := provider.Usage{
+		InputTokens:  tokens.Messages(counter, request.Messages),
+		OutputTokens: int64(counter.Count(response.Reasoning) + counter.Count(response.Text)),
+	}.Priced(c.info)
+	c.usage.Add(usage)
+	emit(provider.UsageEvent{Usage: usage})
+
+	return reply, nil
+}
+
+// respond returns the next response of the script, or the echo of the last
+// user message without a script.
+func (c *MockClient) respond(messages model.ChatMessages) (Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.script == nil {
+		return Response{Text: echo(messages)}, nil
+	}
+
+	if c.next == len(c.script.Responses) {
+		if !c.script.Loop {
+			return Response{}, ErrScriptExhausted
+		}
+		c.next = 0
+	}
+	response := c.script.Responses[c.next]
+	c.next++
+	return response, nil
+}
+
+func (c *MockClient) chunkDelay() time.Duration {
+	if c.script == nil {
+		return 0
+	}
+	return time.Duration(c.script.ChunkDelay)
+}
+
+func (c *MockClient) callID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls++
+	return fmt.Sprintf("call_mock_%d", c.calls)
+}
+
+func echo(messages model.ChatMessages) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != "user" {
+			continue
+		}
+		for _, part := range messages[i].Parts {
+			if part.Type == "text" && part.Text != "" {
+				return "You said: " + part.Text
+			}
+		}
+	}
+	return "Nothing to echo."
+}
+
+func wait(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *MockClient) CancelRequest() error {
+	if c.cancelRequest != nil {
+		c.cancelRequest()
+	}
+	return nil
+}
+
+func (c *MockClient) Usage() provider.Usage {
+	return c.usage
+}
+
+func (c *MockClient) Capabilities() provider.Capabilities {
+	return c.capabilities
+}
@@ -0,0 +1,178 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/TZGyn/kode/internal/provider"
+)
+
+// The subset of the Ollama API used by kode, see
+// https://github.com/ollama/ollama/blob/main/docs/api.md
+
+type chatRequest struct {
+	Model     string         `json:"model"`
+	Messages  []message      `json:"messages"`
+	Tools     []toolParam    `json:"tools,omitempty"`
+	Stream    bool           `json:"stream"`
+	KeepAlive string         `json:"keep_alive,omitempty"`
+	Options   map[string]any `json:"options,omitempty"`
+}
+
+type message struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	Thinking  string     `json:"thinking,omitempty"`
+	Images    [][]byte   `json:"images,omitempty"`
+	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+	ToolName  string     `json:"tool_name,omitempty"`
+}
+
+type toolCall struct {
+	Function struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	} `json:"function"`
+}
+
+type toolParam struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description"`
+		Parameters  map[string]any `json:"parameters"`
+	} `json:"function"`
+}
+
+type chatResponse struct {
+	Message         message `json:"message"`
+	Done            bool    `json:"done"`
+	PromptEvalCount int64   `json:"prompt_eval_count"`
+	EvalCount       int64   `json:"eval_count"`
+	Error           string  `json:"error"`
+}
+
+type tagsResponse struct {
+	Models []struct {
+		Name string `json:"name"`
+	} `json:"models"`
+}
+
+type showResponse struct {
+	Capabilities []string       `json:"capabilities"`
+	ModelInfo    map[string]any `json:"model_info"`
+}
+
+// api is a client of the Ollama daemon listening at baseURL.
+type api struct {
+	baseURL string
+	http    *http.Client
+}
+
+// chat streams a chat request, calling handle for every response line.
+func (a *api) chat(ctx context.Context, request chatRequest, handle func(chatResponse) error) error {
+	response, err := a.post(ctx, "/api/chat", request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		chunk := chatResponse{}
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return err
+		}
+		if chunk.Error != "" {
+			return errors.New("ollama: " + chunk.Error)
+		}
+		if err := handle(chunk); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (a *api) tags(ctx context.Context) (tagsResponse, error) {
+	tags := tagsResponse{}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, a.baseURL+"/api/tags", nil)
+	if err != nil {
+		return tags, err
+	}
+	response, err := a.do(request)
+	if err != nil {
+		return tags, err
+	}
+	defer response.Body.Close()
+
+	return tags, json.NewDecoder(response.Body).Decode(&tags)
+}
+
+func (a *api) show(ctx context.Context, name string) (showResponse, error) {
+	show := showResponse{}
+
+	response, err := a.post(ctx, "/api/show", map[string]string{"model": name})
+	if err != nil {
+		return show, err
+	}
+	defer response.Body.Close()
+
+	return show, json.NewDecoder(response.Body).Decode(&show)
+}
+
+func (a *api) post(ctx context.Context, path string, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	return a.do(request)
+}
+
+// do sends request, turning a refused connection into an error telling to
+// start the daemon and error statuses into the error Ollama returned.
+func (a *api) do(request *http.Request) (*http.Response, error) {
+	response, err := a.http.Do(request)
+	if err != nil {
+		if opErr := (*net.OpError)(nil); errors.As(err, &opErr) && opErr.Op == "dial" {
+			return nil, fmt.Errorf("ollama is not running at %s, start it with `ollama serve`: %w", a.baseURL, err)
+		}
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+
+		failure := struct {
+			Error string `json:"error"`
+		}{}
+		json.NewDecoder(response.Body).Decode(&failure)
+		if failure.Error == "" {
+			failure.Error = response.Status
+		}
+		return nil, provider.NewStatusError(response.StatusCode, response.Header,
+			fmt.Errorf("ollama: %s", strings.TrimSpace(failure.Error)))
+	}
+
+	return response, nil
+}
@@ -0,0 +1,19 @@
+package ollama
+
+import (
+	"github.com/TZGyn/kode/internal/tool"
+)
+
+var tools = toolParams(tool.Default)
+
+func toolParams(registry *tool.Registry) []toolParam {
+	params := []toolParam{}
+	for _, t := range registry.Tools() {
+		param := toolParam{Type: "function"}
+		param.Function.Name = t.Name()
+		param.Function.Description = t.Description()
+		param.Function.Parameters = t.Parameters().Map()
+		params = append(params, param)
+	}
+	return params
+}
@@ -0,0 +1,127 @@
+package ollama
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+)
+
+func FromChatMessages(c model.ChatMessages) ([]message, error) {
+	messages := []message{}
+
+	for _, content := range c {
+		// Tool results are sent back one message each, named after the tool.
+		if content.Role == "tool" {
+			for _, part := range content.Parts {
+				if part.Type == "tool-result" {
+					messages = append(messages, message{
+						Role:     "tool",
+						Content:  part.ResultText(),
+						ToolName: part.ToolCallName,
+					})
+				}
+			}
+			continue
+		}
+
+		m := message{Role: content.Role}
+		text := []string{}
+		for _, part := range content.Parts {
+			switch part.Type {
+			case "text":
+				if part.Text != "" {
+					text = append(text, part.Text)
+				}
+			case "reasoning":
+				if content.Provider == models.ProviderOllama {
+					m.Thinking += part.Reasoning
+				}
+			case "file":
+				// Ollama only takes images, text files are inlined.
+				if strings.HasPrefix(part.MediaType, "image/") {
+					m.Images = append(m.Images, part.Data)
+				} else if part.IsText() {
+					text = append(text, part.FileText())
+				}
+			case "tool-call":
+				call := toolCall{}
+				call.Function.Name = part.ToolCallName
+				call.Function.Arguments = part.ToolCallArgs
+				if call.Function.Arguments == nil {
+					call.Function.Arguments = map[string]any{}
+				}
+				m.ToolCalls = append(m.ToolCalls, call)
+			}
+		}
+		m.Content = strings.Join(text, "\n")
+
+		if m.Content == "" && m.Thinking == "" && len(m.Images) == 0 && len(m.ToolCalls) == 0 {
+			continue
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, nil
+}
+
+// ToChatMessages converts the history back, consecutive tool messages are
+// grouped in a single message like the other providers do. Ollama does not
+// identify tool calls, they are given an id and their results are paired with
+// them in order.
+func ToChatMessages(messages []message) (model.ChatMessages, error) {
+	c := model.ChatMessages{}
+	pending := []*model.ChatPart{}
+
+	for _, m := range messages {
+		if m.Role == "tool" {
+			id := ""
+			if len(pending) > 0 {
+				id, pending = pending[0].ToolCallID, pending[1:]
+			}
+
+			part := model.NewToolResult(id, m.ToolName, m.Content)
+			if last := len(c) - 1; last >= 0 && c[last].Role == "tool" {
+				c[last].Parts = append(c[last].Parts, part)
+			} else {
+				c = append(c, &model.ChatMessage{Role: "tool", Parts: []*model.ChatPart{part}})
+			}
+			continue
+		}
+
+		parts := []*model.ChatPart{}
+		if m.Thinking != "" {
+			parts = append(parts, &model.ChatPart{Type: "reasoning", Reasoning: m.Thinking})
+		}
+		if m.Content != "" {
+			parts = append(parts, &model.ChatPart{Type: "text", Text: m.Content})
+		}
+		for _, image := range m.Images {
+			parts = append(parts, &model.ChatPart{Type: "file", MediaType: http.DetectContentType(image), Data: image})
+		}
+		pending = []*model.ChatPart{}
+		for _, call := range m.ToolCalls {
+			part := &model.ChatPart{
+				Type:         "tool-call",
+				ToolCallID:   callID(),
+				ToolCallName: call.Function.Name,
+				ToolCallArgs: call.Function.Arguments,
+			}
+			pending = append(pending, part)
+			parts = append(parts, part)
+		}
+
+		c = append(c, &model.ChatMessage{Role: m.Role, Parts: parts})
+	}
+
+	return c, nil
+}
+
+func callID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "call_" + hex.EncodeToString(b)
+}
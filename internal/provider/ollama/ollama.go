@@ -0,0 +1,267 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/TZGyn/kode/internal/provider/prompt"
+)
+
+const (
+	DefaultBaseURL = "http://localhost:11434"
+
+	// discoveryTimeout bounds the startup discovery, so that kode does not
+	// wait on a daemon that is not answering.
+	discoveryTimeout = 2 * time.Second
+
+	// maxContext caps the context requested from Ollama, which allocates it
+	// upfront whatever the model supports.
+	maxContext = 32768
+	// defaultContext is used for models not reporting their context length.
+	defaultContext = 8192
+)
+
+type Config struct {
+	BaseURL   string `json:"base_url"`
+	KeepAlive string `json:"keep_alive"`
+	Model     string `json:"model"`
+	// ContextWindow is the context requested for the model.
+	ContextWindow int64 `json:"context_window"`
+}
+
+type OllamaClient struct {
+	context       context.Context
+	cancelRequest context.CancelFunc
+
+	api       *api
+	model     string
+	keepAlive string
+	numCtx    int64
+
+	info         models.Model
+	capabilities provider.Capabilities
+	usage        provider.Usage
+}
+
+func init() {
+	provider.Register(models.ProviderOllama, New)
+	provider.RegisterSetup(setup)
+}
+
+// DefaultConfig returns the config of model, Ollama being configured by the
+// ollama entry of the providers of the config, by OLLAMA_HOST otherwise.
+func DefaultConfig(c *config.Config, model string) Config {
+	declared := c.Providers[models.ProviderOllama]
+
+	baseURL := declared.BaseURL
+	if baseURL == "" {
+		baseURL = os.Getenv("OLLAMA_HOST")
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+
+	return Config{
+		BaseURL:   strings.TrimSuffix(baseURL, "/"),
+		KeepAlive: declared.KeepAlive,
+		Model:     model,
+	}
+}
+
+func New(c *config.Config, m models.Model) (provider.Provider, error) {
+	config := DefaultConfig(c, m.APIModel)
+	config.ContextWindow = m.ContextWindow
+
+	client, err := Create(config)
+	if err != nil {
+		return nil, err
+	}
+
+	client.info = m
+	client.capabilities = provider.CapabilitiesOf(m)
+
+	return client, nil
+}
+
+func Create(config Config) (*OllamaClient, error) {
+	if config.Model == "" {
+		return nil, errors.New("invalid model")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &OllamaClient{
+		context:       ctx,
+		cancelRequest: cancel,
+
+		api:       &api{baseURL: config.BaseURL, http: http.DefaultClient},
+		model:     config.Model,
+		keepAlive: config.KeepAlive,
+		numCtx:    config.ContextWindow,
+	}, nil
+}
+
+// setup adds the installed models to the model table, along with the ones
+// declared in the config. A daemon that cannot be reached is only an error
+// when Ollama is the selected provider.
+func setup(c *config.Config) error {
+	// The name was taken by a provider of another type.
+	declared := c.Providers[models.ProviderOllama]
+	if declared.Type != "" && declared.Type != string(models.ProviderOllama) {
+		return nil
+	}
+	config := DefaultConfig(c, "")
+	client := &api{baseURL: config.BaseURL, http: http.DefaultClient}
+
+	listed := []models.Model{}
+	for _, m := range declared.Models {
+		listed = append(listed, m.Info(models.ProviderOllama))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+
+	discovered, err := discover(ctx, client)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("ollama is not answering at %s: %w", config.BaseURL, err)
+	}
+	if err != nil && c.DEFAULT_PROVIDER == models.ProviderOllama {
+		return err
+	}
+	for _, m := range discovered {
+		if !slices.ContainsFunc(listed, func(other models.Model) bool { return other.ID == m.ID }) {
+			listed = append(listed, m)
+		}
+	}
+
+	if len(listed) > 0 {
+		models.Add(models.ProviderOllama, listed...)
+	}
+	return nil
+}
+
+// discover lists the installed models with their capabilities.
+func discover(ctx context.Context, client *api) ([]models.Model, error) {
+	tags, err := client.tags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	discovered := []models.Model{}
+	for _, installed := range tags.Models {
+		m := models.Model{
+			ID:            models.ModelID(installed.Name),
+			Name:          installed.Name,
+			Provider:      models.ProviderOllama,
+			APIModel:      installed.Name,
+			ContextWindow: defaultContext,
+			SupportsTools: true,
+		}
+
+		// Older versions of Ollama do not report capabilities.
+		show, err := client.show(ctx, installed.Name)
+		if err == nil && len(show.Capabilities) > 0 {
+			m.SupportsTools = slices.Contains(show.Capabilities, "tools")
+			m.SupportsAttachments = slices.Contains(show.Capabilities, "vision")
+			m.CanReason = slices.Contains(show.Capabilities, "thinking")
+		}
+		for key, value := range show.ModelInfo {
+			if length, ok := value.(float64); ok && strings.HasSuffix(key, ".context_length") {
+				m.ContextWindow = min(int64(length), maxContext)
+			}
+		}
+
+		discovered = append(discovered, m)
+	}
+
+	return discovered, nil
+}
+
+func (c *OllamaClient) Stream(ctx context.Context, request provider.Request, emit provider.EmitFunc) (*model.ChatMessage, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(c.context, cancel)()
+
+	messages, err := FromChatMessages(request.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	params := chatRequest{
+		Model:     c.model,
+		Messages:  append([]message{{Role: "system", Content: prompt.SystemPrompt()}}, messages...),
+		Stream:    true,
+		KeepAlive: c.keepAlive,
+	}
+	// Ollama has no way to forbid calling declared tools.
+	if c.capabilities.Tools && !request.NoTools {
+		params.Tools = tools
+	}
+	if c.numCtx > 0 {
+		params.Options = map[string]any{"num_ctx": c.numCtx}
+	}
+
+	reply := message{Role: "assistant"}
+	usage := provider.Usage{}
+	err = c.api.chat(ctx, params, func(chunk chatResponse) error {
+		if chunk.Message.Content != "" {
+			reply.Content += chunk.Message.Content
+			emit(provider.TextDeltaEvent{Text: chunk.Message.Content})
+		}
+		reply.Thinking += chunk.Message.Thinking
+		for _, call := range chunk.Message.ToolCalls {
+			reply.ToolCalls = append(reply.ToolCalls, call)
+			emit(provider.ToolCallStartEvent{Name: call.Function.Name})
+		}
+		if chunk.Done {
+			usage.InputTokens = chunk.PromptEvalCount
+			usage.OutputTokens = chunk.EvalCount
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	usage = usage.Priced(c.info)
+	c.usage.Add(usage)
+	emit(provider.UsageEvent{Usage: usage})
+
+	if reply.Content == "" && len(reply.ToolCalls) == 0 {
+		return nil, errors.New("empty response")
+	}
+	converted, err := ToChatMessages([]message{reply})
+	if err != nil {
+		return nil, err
+	}
+	converted[0].Provider, converted[0].Model = c.info.Provider, c.info.ID
+	return converted[0], nil
+}
+
+func (c *OllamaClient) CancelRequest() error {
+	if c.cancelRequest != nil {
+		c.cancelRequest()
+	}
+	return nil
+}
+
+func (c *OllamaClient) Usage() provider.Usage {
+	return c.usage
+}
+
+func (c *OllamaClient) Capabilities() provider.Capabilities {
+	return c.capabilities
+}
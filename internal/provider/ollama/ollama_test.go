@@ -0,0 +1,183 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/provider"
+)
+
+// daemon mimics the endpoints of Ollama used by kode.
+func daemon(t *testing.T, chat func(w http.ResponseWriter, request chatRequest)) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			fmt.Fprint(w, `{"models":[{"name":"llama3.2:latest"},{"name":"qwen3:8b"}]}`)
+		case "/api/show":
+			body := map[string]string{}
+			json.NewDecoder(r.Body).Decode(&body)
+			switch body["model"] {
+			case "llama3.2:latest":
+				fmt.Fprint(w, `{"capabilities":["completion","tools"],"model_info":{"llama.context_length":131072}}`)
+			case "qwen3:8b":
+				fmt.Fprint(w, `{"capabilities":["completion","tools","thinking"],"model_info":{"qwen3.context_length":16384}}`)
+			default:
+				http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+			}
+		case "/api/chat":
+			request := chatRequest{}
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Error(err)
+			}
+			chat(w, request)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// restore puts the ollama models of the model table back once the test is
+// done, setup adding to it.
+func restore(t *testing.T) {
+	t.Helper()
+
+	saved, ok := models.Models[models.ProviderOllama]
+	delete(models.Models, models.ProviderOllama)
+	t.Cleanup(func() {
+		if ok {
+			models.Models[models.ProviderOllama] = saved
+		} else {
+			delete(models.Models, models.ProviderOllama)
+		}
+	})
+}
+
+func TestSetupDiscovers(t *testing.T) {
+	restore(t)
+	server := daemon(t, nil)
+	t.Setenv("OLLAMA_HOST", server.URL)
+
+	if err := setup(&config.Config{}); err != nil {
+		t.Fatal(err)
+	}
+
+	llama, ok := models.Find(models.ProviderOllama, "llama3.2:latest")
+	if !ok {
+		t.Fatal("llama3.2 was not discovered")
+	}
+	if !llama.SupportsTools || llama.CanReason || llama.ContextWindow != maxContext {
+		t.Errorf("llama3.2 = %+v, want tools and the context capped", llama)
+	}
+
+	qwen, ok := models.Find(models.ProviderOllama, "qwen3:8b")
+	if !ok {
+		t.Fatal("qwen3 was not discovered")
+	}
+	if !qwen.CanReason || qwen.ContextWindow != 16384 {
+		t.Errorf("qwen3 = %+v, want reasoning and its context", qwen)
+	}
+}
+
+func TestSetupWithoutDaemon(t *testing.T) {
+	restore(t)
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	t.Setenv("OLLAMA_HOST", server.URL)
+
+	if err := setup(&config.Config{DEFAULT_PROVIDER: models.ProviderAnthropic}); err != nil {
+		t.Errorf("setup failed while another provider is selected: %v", err)
+	}
+	if _, ok := models.Models[models.ProviderOllama]; ok {
+		t.Error("an empty ollama provider was added")
+	}
+
+	err := setup(&config.Config{DEFAULT_PROVIDER: models.ProviderOllama, DEFAULT_MODEL: "llama3.2:latest"})
+	if err == nil || !strings.Contains(err.Error(), "ollama is not running") {
+		t.Errorf("setup with ollama selected = %v, want it not running", err)
+	}
+}
+
+func TestStream(t *testing.T) {
+	requests := make(chan chatRequest, 1)
+	server := daemon(t, func(w http.ResponseWriter, request chatRequest) {
+		requests <- request
+		for _, line := range []string{
+			`{"message":{"role":"assistant","content":"Let me "}}`,
+			`{"message":{"role":"assistant","content":"look."}}`,
+			`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"cat_file","arguments":{"filePath":"main.go"}}}]}}`,
+			`{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":42,"eval_count":7}`,
+		} {
+			fmt.Fprintln(w, line)
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	client, err := Create(Config{BaseURL: server.URL, Model: "llama3.2:latest", ContextWindow: 8192})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.info = models.Model{ID: "llama3.2:latest", Provider: models.ProviderOllama}
+	client.capabilities = provider.Capabilities{Tools: true}
+
+	text := ""
+	calls := []string{}
+	messages := model.ChatMessages{{Role: "user", Parts: []*model.ChatPart{{Type: "text", Text: "What is in main.go?"}}}}
+	reply, err := client.Stream(context.Background(), provider.Request{Messages: messages}, func(event provider.Event) {
+		switch event := event.(type) {
+		case provider.TextDeltaEvent:
+			text += event.Text
+		case provider.ToolCallStartEvent:
+			calls = append(calls, event.Name)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := <-requests
+	if request.Model != "llama3.2:latest" || !request.Stream || request.Options["num_ctx"] != 8192.0 || len(request.Tools) == 0 {
+		t.Errorf("request = %+v", request)
+	}
+	if last := request.Messages[len(request.Messages)-1]; last.Role != "user" || last.Content != "What is in main.go?" {
+		t.Errorf("last message sent = %+v", last)
+	}
+
+	if text != "Let me look." || len(calls) != 1 || calls[0] != "cat_file" {
+		t.Errorf("streamed %q and calls %v", text, calls)
+	}
+	if len(reply.Parts) != 2 || reply.Parts[1].Type != "tool-call" || reply.Parts[1].ToolCallArgs["filePath"] != "main.go" {
+		t.Errorf("reply = %+v", reply.Parts)
+	}
+	if usage := client.Usage(); usage.InputTokens != 42 || usage.OutputTokens != 7 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestStreamError(t *testing.T) {
+	server := daemon(t, func(w http.ResponseWriter, request chatRequest) {
+		http.Error(w, `{"error":"model \"missing\" not found, try pulling it first"}`, http.StatusNotFound)
+	})
+
+	client, err := Create(Config{BaseURL: server.URL, Model: "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := model.ChatMessages{{Role: "user", Parts: []*model.ChatPart{{Type: "text", Text: "hi"}}}}
+	_, err = client.Stream(context.Background(), provider.Request{Messages: messages}, func(provider.Event) {})
+	if err == nil || !strings.Contains(err.Error(), "try pulling it first") {
+		t.Errorf("err = %v, want the error of Ollama", err)
+	}
+}
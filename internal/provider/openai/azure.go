@@ -0,0 +1,64 @@
+package openai
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/provider"
+)
+
+const DefaultAzureAPIVersion = "2024-10-21"
+
+func init() {
+	provider.Register(models.ProviderAzure, NewAzure)
+}
+
+// NewAzure returns a client of the deployment of m. Azure routes requests by
+// deployment in the path, versions them with the api-version query parameter
+// and authenticates them with the api-key header.
+func NewAzure(c *config.Config, m models.Model) (provider.Provider, error) {
+	azure := c.Azure
+	if azure.Endpoint == "" {
+		azure.Endpoint = os.Getenv("AZURE_OPENAI_ENDPOINT")
+	}
+	if azure.APIKey == "" {
+		azure.APIKey = os.Getenv("AZURE_OPENAI_API_KEY")
+	}
+	if azure.APIVersion == "" {
+		azure.APIVersion = os.Getenv("OPENAI_API_VERSION")
+	}
+	if azure.APIVersion == "" {
+		azure.APIVersion = DefaultAzureAPIVersion
+	}
+
+	if azure.Endpoint == "" {
+		return nil, errors.New("no Azure OpenAI endpoint, set azure.endpoint in kode.json or AZURE_OPENAI_ENDPOINT")
+	}
+	if azure.APIKey == "" {
+		return nil, errors.New("no Azure OpenAI key, set azure.api_key in kode.json or AZURE_OPENAI_API_KEY")
+	}
+
+	deployment := azure.Deployments[m.ID]
+	if deployment == "" {
+		deployment = string(m.ID)
+	}
+
+	client, err := Create(Config{
+		Model:   deployment,
+		BaseURL: strings.TrimSuffix(azure.Endpoint, "/") + "/openai/deployments/" + url.PathEscape(deployment),
+		Headers: map[string]string{"api-key": azure.APIKey},
+		Query:   map[string]string{"api-version": azure.APIVersion},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client.info = m
+	client.capabilities = provider.CapabilitiesOf(m)
+
+	return client, nil
+}
@@ -0,0 +1,52 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/models"
+)
+
+func TestAzure(t *testing.T) {
+	server, requests := endpoint(t)
+	t.Setenv("OPENAI_API_KEY", "sk-openai")
+	t.Setenv("AZURE_OPENAI_API_KEY", "azure-key")
+	t.Setenv("OPENAI_API_VERSION", "")
+
+	c := &config.Config{Azure: config.Azure{
+		Endpoint:    server.URL + "/",
+		Deployments: map[models.ModelID]string{"gpt-4.1": "prod gpt"},
+	}}
+	client, err := NewAzure(c, models.Model{ID: "gpt-4.1", Provider: models.ProviderAzure, SupportsTools: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, text, _ := stream(t, client)
+	if text != "Let me look." {
+		t.Errorf("streamed %q", text)
+	}
+
+	r := <-requests
+	if path := r.URL.EscapedPath(); path != "/openai/deployments/prod%20gpt/chat/completions" {
+		t.Errorf("path = %s, want the deployment path", path)
+	}
+	if version := r.URL.Query().Get("api-version"); version != DefaultAzureAPIVersion {
+		t.Errorf("api-version = %q, want %s", version, DefaultAzureAPIVersion)
+	}
+	if key := r.Header.Get("Api-Key"); key != "azure-key" {
+		t.Errorf("api-key = %q, want azure-key", key)
+	}
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		t.Errorf("Authorization = %q, want none", authorization)
+	}
+}
+
+func TestAzureWithoutEndpoint(t *testing.T) {
+	t.Setenv("AZURE_OPENAI_ENDPOINT", "")
+	t.Setenv("AZURE_OPENAI_API_KEY", "azure-key")
+
+	if _, err := NewAzure(&config.Config{}, models.Model{ID: "gpt-4.1"}); err == nil {
+		t.Error("NewAzure succeeded without an endpoint")
+	}
+}
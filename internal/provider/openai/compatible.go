@@ -0,0 +1,68 @@
+package openai
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/provider"
+)
+
+// TypeCompatible is the type of the providers declared in the config that
+// speak the OpenAI chat completions protocol, like llama.cpp, vLLM or Ollama.
+const TypeCompatible = "openai-compatible"
+
+func init() {
+	provider.RegisterSetup(setupCompatible)
+}
+
+// setupCompatible registers the openai-compatible providers of the config
+// along with their models.
+func setupCompatible(c *config.Config) error {
+	for name, declared := range c.Providers {
+		if declared.Type != TypeCompatible {
+			continue
+		}
+		if _, ok := models.Models[name]; ok {
+			return fmt.Errorf("provider %q is already defined", name)
+		}
+		if declared.BaseURL == "" {
+			return fmt.Errorf("provider %q has no base_url", name)
+		}
+		if len(declared.Models) == 0 {
+			return fmt.Errorf("provider %q declares no models", name)
+		}
+
+		for _, m := range declared.Models {
+			models.Add(name, m.Info(name))
+		}
+		provider.Register(name, NewCompatible)
+	}
+
+	return nil
+}
+
+func NewCompatible(c *config.Config, m models.Model) (provider.Provider, error) {
+	declared := c.Providers[m.Provider]
+
+	headers := map[string]string{}
+	for key, value := range declared.Headers {
+		headers[key] = os.ExpandEnv(value)
+	}
+
+	client, err := Create(Config{
+		OPENAI_API_KEY: os.ExpandEnv(declared.APIKey),
+		Model:          m.APIModel,
+		BaseURL:        declared.BaseURL,
+		Headers:        headers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client.info = m
+	client.capabilities = provider.CapabilitiesOf(m)
+
+	return client, nil
+}
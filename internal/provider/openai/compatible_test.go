@@ -0,0 +1,87 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/models"
+)
+
+func TestCompatible(t *testing.T) {
+	server, requests := endpoint(t)
+	t.Setenv("LOCAL_KEY", "sk-local")
+	t.Setenv("TENANT", "team-a")
+	t.Setenv("OPENAI_API_KEY", "sk-openai")
+
+	c := &config.Config{Providers: map[models.ModelProvider]config.Provider{
+		"local": {
+			Type:    TypeCompatible,
+			BaseURL: server.URL + "/v1",
+			APIKey:  "$LOCAL_KEY",
+			Headers: map[string]string{"X-Tenant": "${TENANT}"},
+			Models:  []config.Model{{ID: "qwen", APIModel: "qwen2.5-coder:32b"}},
+		},
+	}}
+	t.Cleanup(func() { delete(models.Models, "local") })
+	if err := setupCompatible(c); err != nil {
+		t.Fatal(err)
+	}
+
+	m, ok := models.Find("local", "qwen")
+	if !ok {
+		t.Fatal("the declared model was not added")
+	}
+	client, err := NewCompatible(c, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply, text, calls := stream(t, client)
+	if text != "Let me look." || len(calls) != 1 {
+		t.Errorf("streamed %q and calls %v", text, calls)
+	}
+	if call := reply.Parts[len(reply.Parts)-1]; call.ToolCallName != "cat_file" || call.ToolCallArgs["filePath"] != "main.go" {
+		t.Errorf("tool call = %+v", call)
+	}
+
+	r := <-requests
+	if r.URL.Path != "/v1/chat/completions" {
+		t.Errorf("path = %s, want /v1/chat/completions", r.URL.Path)
+	}
+	if authorization := r.Header.Get("Authorization"); authorization != "Bearer sk-local" {
+		t.Errorf("Authorization = %q, want the expanded key", authorization)
+	}
+	if tenant := r.Header.Get("X-Tenant"); tenant != "team-a" {
+		t.Errorf("X-Tenant = %q, want the expanded header", tenant)
+	}
+}
+
+func TestCompatibleWithoutKey(t *testing.T) {
+	server, requests := endpoint(t)
+	t.Setenv("OPENAI_API_KEY", "sk-openai")
+
+	c := &config.Config{Providers: map[models.ModelProvider]config.Provider{
+		"local": {Type: TypeCompatible, BaseURL: server.URL, Models: []config.Model{{ID: "qwen"}}},
+	}}
+	client, err := NewCompatible(c, c.Providers["local"].Models[0].Info("local"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream(t, client)
+
+	if authorization := (<-requests).Header.Get("Authorization"); authorization != "" {
+		t.Errorf("Authorization = %q, want none", authorization)
+	}
+}
+
+func TestSetupCompatibleErrors(t *testing.T) {
+	for name, declared := range map[string]config.Provider{
+		"no base url": {Type: TypeCompatible, Models: []config.Model{{ID: "qwen"}}},
+		"no models":   {Type: TypeCompatible, BaseURL: "http://localhost:8080"},
+	} {
+		c := &config.Config{Providers: map[models.ModelProvider]config.Provider{"local": declared}}
+		if err := setupCompatible(c); err == nil {
+			t.Errorf("%s: setup succeeded", name)
+		}
+	}
+}
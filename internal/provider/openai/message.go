@@ -0,0 +1,154 @@
+package openai
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/openai/openai-go"
+)
+
+func FromChatMessages(c model.ChatMessages) ([]openai.ChatCompletionMessageParamUnion, error) {
+	openAIMessages := []openai.ChatCompletionMessageParamUnion{}
+
+	for _, content := range c {
+		text := ""
+		files := []openai.ChatCompletionContentPartUnionParam{}
+		toolCalls := []openai.ChatCompletionMessageToolCallParam{}
+
+		for _, part := range content.Parts {
+			switch part.Type {
+			case "text":
+				text += part.Text
+			case "file":
+				files = append(files, filePart(part))
+			case "tool-call":
+				args, err := json.Marshal(part.ToolCallArgs)
+				if err != nil {
+					return nil, err
+				}
+				toolCalls = append(toolCalls, openai.ChatCompletionMessageToolCallParam{
+					ID: part.ToolCallID,
+					Function: openai.ChatCompletionMessageToolCallFunctionParam{
+						Name:      part.ToolCallName,
+						Arguments: string(args),
+					},
+				})
+			case "tool-result":
+				openAIMessages = append(openAIMessages, openai.ToolMessage(part.ResultText(), part.ToolCallID))
+			}
+		}
+
+		switch content.Role {
+		case "user":
+			if len(files) > 0 {
+				if text != "" {
+					files = append([]openai.ChatCompletionContentPartUnionParam{openai.TextContentPart(text)}, files...)
+				}
+				openAIMessages = append(openAIMessages, openai.UserMessage(files))
+			} else if text != "" {
+				openAIMessages = append(openAIMessages, openai.UserMessage(text))
+			}
+		case "assistant":
+			if text == "" && len(toolCalls) == 0 {
+				continue
+			}
+			assistant := openai.ChatCompletionAssistantMessageParam{ToolCalls: toolCalls}
+			if text != "" {
+				assistant.Content.OfString = openai.String(text)
+			}
+			openAIMessages = append(openAIMessages, openai.ChatCompletionMessageParamUnion{OfAssistant: &assistant})
+		}
+	}
+
+	return openAIMessages, nil
+}
+
+// filePart sends images as images and text files inlined, other files are
+// sent as files.
+func filePart(part *model.ChatPart) openai.ChatCompletionContentPartUnionParam {
+	if part.IsText() {
+		return openai.TextContentPart(part.FileText())
+	}
+
+	url := "data:" + part.MediaType + ";base64," + base64.StdEncoding.EncodeToString(part.Data)
+	if strings.HasPrefix(part.MediaType, "image/") {
+		return openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: url})
+	}
+	return openai.FileContentPart(openai.ChatCompletionContentPartFileFileParam{
+		FileData: openai.String(url),
+		Filename: openai.String(part.Filename),
+	})
+}
+
+// ToChatMessages converts the history back, consecutive tool messages are
+// grouped in a single message like the other providers do.
+func ToChatMessages(messages []openai.ChatCompletionMessageParamUnion) (model.ChatMessages, error) {
+	c := model.ChatMessages{}
+	names := map[string]string{}
+
+	for _, message := range messages {
+		if message.OfUser != nil {
+			parts := []*model.ChatPart{}
+			if text := message.OfUser.Content.OfString.Value; text != "" {
+				parts = append(parts, &model.ChatPart{Type: "text", Text: text})
+			}
+			for _, content := range message.OfUser.Content.OfArrayOfContentParts {
+				switch {
+				case content.OfText != nil:
+					parts = append(parts, &model.ChatPart{Type: "text", Text: content.OfText.Text})
+				case content.OfImageURL != nil:
+					if part := dataFile(content.OfImageURL.ImageURL.URL, ""); part != nil {
+						parts = append(parts, part)
+					}
+				case content.OfFile != nil:
+					if part := dataFile(content.OfFile.File.FileData.Value, content.OfFile.File.Filename.Value); part != nil {
+						parts = append(parts, part)
+					}
+				}
+			}
+			c = append(c, &model.ChatMessage{Role: "user", Parts: parts})
+		}
+		if message.OfTool != nil {
+			text := message.OfTool.Content.OfString.Value
+			for _, part := range message.OfTool.Content.OfArrayOfContentParts {
+				text += part.Text
+			}
+
+			id := message.OfTool.ToolCallID
+			part := model.NewToolResult(id, names[id], text)
+			if last := len(c) - 1; last >= 0 && c[last].Role == "tool" {
+				c[last].Parts = append(c[last].Parts, part)
+			} else {
+				c = append(c, &model.ChatMessage{Role: "tool", Parts: []*model.ChatPart{part}})
+			}
+		}
+		if message.OfAssistant != nil {
+			parts := []*model.ChatPart{}
+			if text := message.OfAssistant.Content.OfString.Value; text != "" {
+				parts = append(parts, &model.ChatPart{Type: "text", Text: text})
+			}
+			for _, toolCall := range message.OfAssistant.ToolCalls {
+				names[toolCall.ID] = toolCall.Function.Name
+				parts = append(parts, model.NewToolCall(toolCall.ID, toolCall.Function.Name, toolCall.Function.Arguments))
+			}
+			c = append(c, &model.ChatMessage{Role: "assistant", Parts: parts})
+		}
+	}
+
+	return c, nil
+}
+
+// dataFile returns the file part of a base64 data URL, nil for other URLs.
+func dataFile(url string, filename string) *model.ChatPart {
+	header, encoded, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ";base64,")
+	if !ok || !strings.HasPrefix(url, "data:") {
+		return nil
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil
+	}
+	return &model.ChatPart{Type: "file", Filename: filename, MediaType: header, Data: data}
+}
@@ -0,0 +1,38 @@
+package openai
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func TestToChatMessagesMalformedArguments(t *testing.T) {
+	assistant := openai.ChatCompletionAssistantMessageParam{
+		ToolCalls: []openai.ChatCompletionMessageToolCallParam{
+			{ID: "call_1", Function: openai.ChatCompletionMessageToolCallFunctionParam{Name: "cat_file", Arguments: `{"filePath": "main.go"}`}},
+			{ID: "call_2", Function: openai.ChatCompletionMessageToolCallFunctionParam{Name: "cat_file", Arguments: `{"filePath": "main.go"`}},
+		},
+	}
+
+	c, err := ToChatMessages([]openai.ChatCompletionMessageParamUnion{{OfAssistant: &assistant}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := c[0].Parts
+	if len(parts) != 2 {
+		t.Fatalf("got %d tool calls, want both kept", len(parts))
+	}
+	if parts[0].ArgsError() != "" || parts[0].ToolCallArgs["filePath"] != "main.go" {
+		t.Errorf("valid call: %+v", parts[0])
+	}
+	if !strings.Contains(parts[1].ArgsError(), `{"filePath": "main.go"`) {
+		t.Errorf("malformed call: ArgsError() = %q", parts[1].ArgsError())
+	}
+
+	// The kept call can still be sent back.
+	if _, err := FromChatMessages(c); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,18 +2,29 @@ package openai
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"time"
 
-	"github.com/TZGyn/kode/internal/tool"
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/TZGyn/kode/internal/provider/cassette"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
 )
 
 type Config struct {
-	OPENAI_API_KEY string `json:"OPENAI_API_KEY"`
-	Model          string `json:"model"`
+	OPENAI_API_KEY string            `json:"OPENAI_API_KEY"`
+	Model          string            `json:"model"`
+	BaseURL        string            `json:"base_url,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Query          map[string]string `json:"query,omitempty"`
+	// HTTPClient sends the requests, through the cassette set in the
+	// environment if any.
+	HTTPClient *http.Client `json:"-"`
 }
 
 type OpenAIClient struct {
@@ -23,7 +34,13 @@ type OpenAIClient struct {
 	client openai.Client
 	model  string
 
-	Messages []openai.ChatCompletionMessageParamUnion
+	info         models.Model
+	capabilities provider.Capabilities
+	usage        provider.Usage
+}
+
+func init() {
+	provider.Register(models.ProviderOpenAI, New)
 }
 
 func DefaultConfig(apiKey string, model string) Config {
@@ -33,12 +50,47 @@ func DefaultConfig(apiKey string, model string) Config {
 	}
 }
 
+func New(c *config.Config, m models.Model) (provider.Provider, error) {
+	client, err := Create(DefaultConfig(c.OPENAI_API_KEY, m.APIModel))
+	if err != nil {
+		return nil, err
+	}
+
+	client.info = m
+	client.capabilities = provider.CapabilitiesOf(m)
+
+	return client, nil
+}
+
 func Create(config Config) (*OpenAIClient, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	httpClient, err := cassette.Client(config.HTTPClient)
+	if err != nil {
+		return nil, err
+	}
 
-	client := openai.NewClient(
-		option.WithAPIKey(config.OPENAI_API_KEY),
-	)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Failed requests are retried by provider.Chain, as configured.
+	options := []option.RequestOption{option.WithAPIKey(config.OPENAI_API_KEY), option.WithMaxRetries(0)}
+	if httpClient != nil {
+		options = append(options, option.WithHTTPClient(httpClient))
+	}
+	if config.BaseURL != "" {
+		options = append(options, option.WithBaseURL(config.BaseURL))
+		// Self-hosted endpoints often need no key, and the one of the
+		// environment is not meant for them.
+		if config.OPENAI_API_KEY == "" {
+			options = append(options, option.WithHeaderDel("authorization"))
+		}
+	}
+	for key, value := range config.Headers {
+		options = append(options, option.WithHeader(key, value))
+	}
+	for key, value := range config.Query {
+		options = append(options, option.WithQuery(key, value))
+	}
+
+	client := openai.NewClient(options...)
 
 	return &OpenAIClient{
 		context:       ctx,
@@ -50,8 +102,16 @@ func Create(config Config) (*OpenAIClient, error) {
 	}, nil
 }
 
-func (c *OpenAIClient) SendMessage(messages []openai.ChatCompletionMessageParamUnion, response *string) error {
-	c.Messages = messages
+func (c *OpenAIClient) Stream(ctx context.Context, request provider.Request, emit provider.EmitFunc) (*model.ChatMessage, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(c.context, cancel)()
+
+	messages, err := FromChatMessages(request.Messages)
+	if err != nil {
+		return nil, err
+	}
+
 	withSystemMessage := []openai.ChatCompletionMessageParamUnion{
 		openai.SystemMessage(
 			fmt.Sprintf(`
@@ -74,34 +134,74 @@ func (c *OpenAIClient) SendMessage(messages []openai.ChatCompletionMessageParamU
 		Messages: withSystemMessage,
 		Tools:    tools,
 		Model:    c.model,
+		StreamOptions: openai.ChatCompletionStreamOptionsParam{
+			IncludeUsage: openai.Bool(true),
+		},
 	}
-
-	completion, err := c.client.Chat.Completions.New(c.context, params)
-	if err != nil {
-		return err
+	if !c.capabilities.Tools {
+		params.Tools = nil
+	} else if request.NoTools {
+		params.ToolChoice.OfAuto = openai.String("none")
 	}
-	*response += completion.Choices[0].Message.Content + "\n"
 
-	toolCalls := completion.Choices[0].Message.ToolCalls
-	if len(toolCalls) == 0 {
-		return nil
-	}
+	stream := c.client.Chat.Completions.NewStreaming(ctx, params)
 
-	params.Messages = append(params.Messages, completion.Choices[0].Message.ToParam())
-	for _, toolCall := range toolCalls {
-		var args map[string]any
-		err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
-		if err != nil {
-			panic(err)
-		}
-		result, err := tool.HandleTool(toolCall.Function.Name, args, response)
-		if err != nil {
+	completion := openai.ChatCompletionAccumulator{}
+	for stream.Next() {
+		chunk := stream.Current()
+		completion.AddChunk(chunk)
+
+		if len(chunk.Choices) == 0 {
 			continue
 		}
-		params.Messages = append(params.Messages, openai.ToolMessage(result, toolCall.ID))
+
+		delta := chunk.Choices[0].Delta
+		if delta.Content != "" {
+			emit(provider.TextDeltaEvent{Text: delta.Content})
+		}
+		for _, toolCall := range delta.ToolCalls {
+			if toolCall.ID != "" {
+				emit(provider.ToolCallStartEvent{ID: toolCall.ID, Name: toolCall.Function.Name})
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, statusError(err)
+	}
+
+	if len(completion.Choices) == 0 {
+		return nil, errors.New("empty response")
 	}
 
-	return c.SendMessage(params.Messages, response)
+	cached := completion.Usage.PromptTokensDetails.CachedTokens
+	usage := provider.Usage{
+		InputTokens:     completion.Usage.PromptTokens - cached,
+		OutputTokens:    completion.Usage.CompletionTokens,
+		CacheReadTokens: cached,
+		ReasoningTokens: completion.Usage.CompletionTokensDetails.ReasoningTokens,
+	}.Priced(c.info)
+	c.usage.Add(usage)
+	emit(provider.UsageEvent{Usage: usage})
+
+	reply, err := ToChatMessages([]openai.ChatCompletionMessageParamUnion{completion.Choices[0].Message.ToParam()})
+	if err != nil {
+		return nil, err
+	}
+	reply[0].Provider, reply[0].Model = c.info.Provider, c.info.ID
+	return reply[0], nil
+}
+
+// statusError exposes the status of API errors, for them to be retried.
+func statusError(err error) error {
+	apiErr := (*openai.Error)(nil)
+	if errors.As(err, &apiErr) {
+		var header http.Header
+		if apiErr.Response != nil {
+			header = apiErr.Response.Header
+		}
+		return provider.NewStatusError(apiErr.StatusCode, header, err)
+	}
+	return err
 }
 
 func (c *OpenAIClient) CancelRequest() error {
@@ -110,3 +210,11 @@ func (c *OpenAIClient) CancelRequest() error {
 	}
 	return nil
 }
+
+func (c *OpenAIClient) Usage() provider.Usage {
+	return c.usage
+}
+
+func (c *OpenAIClient) Capabilities() provider.Capabilities {
+	return c.capabilities
+}
@@ -0,0 +1,86 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/provider"
+)
+
+// chunks is a streamed reply of text followed by a tool call whose arguments
+// come in pieces, then the usage.
+var chunks = []string{
+	`{"id":"1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"role":"assistant","content":"Let me look."}}]}`,
+	`{"id":"1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"cat_file","arguments":""}}]}}]}`,
+	`{"id":"1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"filePath\":"}}]}}]}`,
+	`{"id":"1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"main.go\"}"}}]}}]}`,
+	`{"id":"1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	`{"id":"1","object":"chat.completion.chunk","created":1,"model":"m","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`,
+}
+
+// endpoint stubs a chat completions endpoint streaming chunks, the requests
+// it gets are sent on the returned channel.
+func endpoint(t *testing.T) (*httptest.Server, chan *http.Request) {
+	t.Helper()
+
+	requests := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+// stream sends a prompt, returning the reply and the streamed text and calls.
+func stream(t *testing.T, p provider.Provider) (*model.ChatMessage, string, []string) {
+	t.Helper()
+
+	text := ""
+	calls := []string{}
+	messages := model.ChatMessages{{Role: "user", Parts: []*model.ChatPart{{Type: "text", Text: "What is in main.go?"}}}}
+	reply, err := p.Stream(context.Background(), provider.Request{Messages: messages}, func(event provider.Event) {
+		switch event := event.(type) {
+		case provider.TextDeltaEvent:
+			text += event.Text
+		case provider.ToolCallStartEvent:
+			calls = append(calls, event.ID+" "+event.Name)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reply, text, calls
+}
+
+func TestStream(t *testing.T) {
+	server, _ := endpoint(t)
+
+	client, err := Create(Config{OPENAI_API_KEY: "sk-test", Model: "gpt-4.1", BaseURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.capabilities = provider.Capabilities{Tools: true}
+
+	reply, _, calls := stream(t, client)
+	if len(calls) != 1 || calls[0] != "call_1 cat_file" {
+		t.Errorf("calls = %v, want call_1 cat_file", calls)
+	}
+	if len(reply.Parts) != 2 || reply.Parts[0].Text != "Let me look." {
+		t.Fatalf("reply = %+v", reply.Parts)
+	}
+	if call := reply.Parts[1]; call.ToolCallID != "call_1" || call.ToolCallArgs["filePath"] != "main.go" {
+		t.Errorf("tool call = %+v", call)
+	}
+	if usage := client.Usage(); usage.InputTokens != 10 || usage.OutputTokens != 5 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
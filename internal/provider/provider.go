@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/TZGyn/kode/internal/config"
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+)
+
+type Capabilities struct {
+	Tools       bool
+	Reasoning   bool
+	Attachments bool
+}
+
+// Request is a single model request built from the provider-neutral history.
+// NoTools keeps the tools declared but forbids calling them, so the model has
+// to answer with text.
+type Request struct {
+	Messages model.ChatMessages
+	NoTools  bool
+}
+
+// Provider is a model backend. Stream sends a single request, streams its
+// progress to emit and returns the assistant message, whose tool calls are
+// executed by the agent loop. The request stops when ctx is done or
+// CancelRequest is called.
+type Provider interface {
+	Stream(ctx context.Context, request Request, emit EmitFunc) (*model.ChatMessage, error)
+	CancelRequest() error
+	Usage() Usage
+	Capabilities() Capabilities
+}
+
+type Factory func(c *config.Config, model models.Model) (Provider, error)
+
+var providers = map[models.ModelProvider]Factory{}
+
+// Register makes a provider available under name. It is meant to be called
+// from the init function of the package implementing the provider.
+func Register(name models.ModelProvider, factory Factory) {
+	providers[name] = factory
+}
+
+// Setup prepares providers depending on the config, such as the ones it
+// declares.
+type Setup func(c *config.Config) error
+
+var setups = []Setup{}
+
+// RegisterSetup adds a setup run by Configure, like Register it is meant to
+// be called from an init function.
+func RegisterSetup(setup Setup) {
+	setups = append(setups, setup)
+}
+
+// Configure runs the setups once the config is loaded, before the models are
+// listed or a provider is created.
+func Configure(c *config.Config) error {
+	errs := []error{}
+	for _, setup := range setups {
+		errs = append(errs, setup(c))
+	}
+	return errors.Join(errs...)
+}
+
+func New(c *config.Config, name models.ModelProvider, id models.ModelID) (Provider, error) {
+	factory, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q", name)
+	}
+
+	model, ok := models.Find(name, id)
+	if !ok {
+		return nil, fmt.Errorf("unknown model %q for provider %q", id, name)
+	}
+
+	return factory(c, model)
+}
+
+// CapabilitiesOf derives the capabilities advertised by the model table.
+func CapabilitiesOf(model models.Model) Capabilities {
+	return Capabilities{
+		Tools:       model.SupportsTools,
+		Reasoning:   model.CanReason,
+		Attachments: model.SupportsAttachments,
+	}
+}
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// retryableStatuses are the statuses of rate limits and of providers that are
+// overloaded or unavailable, 529 being the overloaded status of Anthropic.
+var retryableStatuses = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+	529,
+}
+
+// StatusError is an error response of a provider API. RetryAfter is the delay
+// the provider asked to wait before retrying, zero when it did not say.
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Err        error
+}
+
+// NewStatusError returns err with the status of the response and the delay
+// of its retry headers, header may be nil.
+func NewStatusError(statusCode int, header http.Header, err error) *StatusError {
+	return &StatusError{
+		StatusCode: statusCode,
+		RetryAfter: retryAfter(header),
+		Err:        err,
+	}
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether a request failing with err may succeed later, its
+// provider being rate limited, overloaded or unreachable.
+func Retryable(err error) bool {
+	if statusErr := (*StatusError)(nil); errors.As(err, &statusErr) {
+		return slices.Contains(retryableStatuses, statusErr.StatusCode)
+	}
+	netErr := net.Error(nil)
+	return errors.As(err, &netErr)
+}
+
+// retryAfter reads retry-after-ms, sent by Anthropic and OpenAI, or
+// Retry-After in seconds or as a date.
+func retryAfter(header http.Header) time.Duration {
+	if header == nil {
+		return 0
+	}
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+
+	value := header.Get("Retry-After")
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
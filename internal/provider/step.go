@@ -0,0 +1,30 @@
+package provider
+
+import (
+	"context"
+)
+
+// Check is consulted before each model request of a turn, an error stops the
+// turn.
+type Check func(ctx context.Context) error
+
+type checksKey struct{}
+
+// WithCheck adds check to the checks run by Step.
+func WithCheck(ctx context.Context, check Check) context.Context {
+	checks, _ := ctx.Value(checksKey{}).([]Check)
+	checks = append(checks[:len(checks):len(checks)], check)
+	return context.WithValue(ctx, checksKey{}, checks)
+}
+
+// Step runs the checks carried by ctx, it is called by the agent loop before
+// each model request.
+func Step(ctx context.Context) error {
+	checks, _ := ctx.Value(checksKey{}).([]Check)
+	for _, check := range checks {
+		if err := check(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/TZGyn/kode/internal/models"
+)
+
+// Usage counts the tokens of one or more requests. InputTokens excludes the
+// tokens read from or written to the prompt cache, ReasoningTokens are
+// already part of OutputTokens.
+type Usage struct {
+	InputTokens      int64   `json:"input_tokens"`
+	OutputTokens     int64   `json:"output_tokens"`
+	CacheReadTokens  int64   `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int64   `json:"cache_write_tokens,omitempty"`
+	ReasoningTokens  int64   `json:"reasoning_tokens,omitempty"`
+	Cost             float64 `json:"cost"`
+}
+
+func (u *Usage) Add(other Usage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheReadTokens += other.CacheReadTokens
+	u.CacheWriteTokens += other.CacheWriteTokens
+	u.ReasoningTokens += other.ReasoningTokens
+	u.Cost += other.Cost
+}
+
+// Priced returns the usage with its cost computed from the model price table.
+func (u Usage) Priced(model models.Model) Usage {
+	read, write := cachePrices(model)
+	u.Cost = (float64(u.InputTokens)*model.CostPer1MIn +
+		float64(u.OutputTokens)*model.CostPer1MOut +
+		float64(u.CacheReadTokens)*read +
+		float64(u.CacheWriteTokens)*write) / 1e6
+	return u
+}
+
+// cachePrices returns the price of cache reads and writes. The cached columns
+// of the table follow each provider's price sheet: Anthropic lists cache
+// writes as cached input and cache reads as cached output, the others only
+// bill cache reads, listed as cached input. Missing prices fall back to the
+// input price.
+func cachePrices(model models.Model) (float64, float64) {
+	read, write := model.CostPer1MInCached, model.CostPer1MIn
+	if model.Provider == models.ProviderAnthropic || model.Provider == models.ProviderBedrock ||
+		model.Provider == models.ProviderVertexAnthropic {
+		read, write = model.CostPer1MOutCached, model.CostPer1MInCached
+	}
+	if read == 0 {
+		read = model.CostPer1MIn
+	}
+	if write == 0 {
+		write = model.CostPer1MIn
+	}
+	return read, write
+}
+
+// String formats the usage for the footer shown under answers.
+func (u Usage) String() string {
+	result := fmt.Sprintf("%s in  %s out", formatTokens(u.InputTokens), formatTokens(u.OutputTokens))
+	if u.CacheReadTokens > 0 || u.CacheWriteTokens > 0 {
+		result += fmt.Sprintf("  %s cached", formatTokens(u.CacheReadTokens+u.CacheWriteTokens))
+	}
+	if u.ReasoningTokens > 0 {
+		result += fmt.Sprintf("  %s reasoning", formatTokens(u.ReasoningTokens))
+	}
+	return result + "  " + FormatCost(u.Cost)
+}
+
+func FormatCost(cost float64) string {
+	if cost > 0 && cost < 0.01 {
+		return fmt.Sprintf("$%.4f", cost)
+	}
+	return fmt.Sprintf("$%.2f", cost)
+}
+
+func formatTokens(tokens int64) string {
+	switch {
+	case tokens >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(tokens)/1e6)
+	case tokens >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(tokens)/1e3)
+	default:
+		return fmt.Sprintf("%d", tokens)
+	}
+}
@@ -0,0 +1,77 @@
+package vertex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/auth"
+	"cloud.google.com/go/auth/credentials"
+	"cloud.google.com/go/auth/httptransport"
+	"github.com/TZGyn/kode/internal/config"
+)
+
+const Scope = "https://www.googleapis.com/auth/cloud-platform"
+
+// Settings are the resolved Vertex AI settings.
+type Settings struct {
+	Project     string
+	Location    string
+	Credentials *auth.Credentials
+	// BaseURL is the endpoint of the location, or the configured one.
+	BaseURL string
+}
+
+// Resolve reads the credentials, from the service account key of the config
+// or Application Default Credentials, and completes the project and location
+// from the environment and the credentials.
+func Resolve(ctx context.Context, c config.Vertex) (Settings, error) {
+	creds, err := credentials.DetectDefault(&credentials.DetectOptions{
+		Scopes:          []string{Scope},
+		CredentialsFile: c.Credentials,
+	})
+	if err != nil {
+		return Settings{}, fmt.Errorf("vertex: loading the credentials: %w", err)
+	}
+
+	project := c.Project
+	if project == "" {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	if project == "" {
+		project, _ = creds.ProjectID(ctx)
+	}
+	if project == "" {
+		return Settings{}, errors.New("vertex: no project, set vertex.project in the config or GOOGLE_CLOUD_PROJECT")
+	}
+
+	location := c.Location
+	if location == "" {
+		location = os.Getenv("GOOGLE_CLOUD_LOCATION")
+	}
+	if location == "" {
+		return Settings{}, errors.New("vertex: no location, set vertex.location in the config or GOOGLE_CLOUD_LOCATION")
+	}
+
+	baseURL := strings.TrimSuffix(c.Endpoint, "/")
+	if baseURL == "" && location == "global" {
+		baseURL = "https://aiplatform.googleapis.com"
+	} else if baseURL == "" {
+		baseURL = fmt.Sprintf("https://%s-aiplatform.googleapis.com", location)
+	}
+
+	return Settings{
+		Project:     project,
+		Location:    location,
+		Credentials: creds,
+		BaseURL:     baseURL,
+	}, nil
+}
+
+// HTTPClient returns a client authenticated with the credentials.
+func (s Settings) HTTPClient() (*http.Client, error) {
+	return httptransport.NewClient(&httptransport.Options{Credentials: s.Credentials})
+}
@@ -0,0 +1,131 @@
+package vertex
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TZGyn/kode/internal/config"
+)
+
+// serviceAccount writes a service account key whose tokens are exchanged at
+// tokenURL.
+func serviceAccount(t *testing.T, tokenURL string) string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "kode-project",
+		"private_key_id": "key-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "kode@kode-project.iam.gserviceaccount.com",
+		"client_id":      "1",
+		"token_uri":      tokenURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "service-account.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestResolveServiceAccount(t *testing.T) {
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+	t.Setenv("GOOGLE_CLOUD_LOCATION", "")
+
+	grants := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			r.ParseForm()
+			grants <- r.PostForm.Get("grant_type")
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"stub-token","token_type":"Bearer","expires_in":3600}`)
+		case "/api":
+			fmt.Fprint(w, r.Header.Get("Authorization"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	settings, err := Resolve(context.Background(), config.Vertex{
+		Credentials: serviceAccount(t, server.URL+"/token"),
+		Location:    "us-east5",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Project != "kode-project" {
+		t.Errorf("project = %q, want the one of the service account", settings.Project)
+	}
+	if settings.BaseURL != "https://us-east5-aiplatform.googleapis.com" {
+		t.Errorf("base URL = %q", settings.BaseURL)
+	}
+
+	token, err := settings.Credentials.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Value != "stub-token" {
+		t.Errorf("token = %q, want stub-token", token.Value)
+	}
+	if grant := <-grants; grant != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+		t.Errorf("grant_type = %q, want a JWT bearer grant", grant)
+	}
+
+	client, err := settings.HTTPClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := client.Get(server.URL + "/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	authorization := make([]byte, 64)
+	n, _ := response.Body.Read(authorization)
+	if string(authorization[:n]) != "Bearer stub-token" {
+		t.Errorf("Authorization = %q, want Bearer stub-token", authorization[:n])
+	}
+}
+
+func TestResolveLocation(t *testing.T) {
+	t.Setenv("GOOGLE_CLOUD_LOCATION", "")
+	path := serviceAccount(t, "http://127.0.0.1:1/token")
+
+	settings, err := Resolve(context.Background(), config.Vertex{Credentials: path, Location: "global"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.BaseURL != "https://aiplatform.googleapis.com" {
+		t.Errorf("global base URL = %q", settings.BaseURL)
+	}
+
+	if _, err := Resolve(context.Background(), config.Vertex{Credentials: path}); err == nil {
+		t.Error("Resolve succeeded without a location")
+	}
+}
@@ -0,0 +1,249 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/adrg/xdg"
+)
+
+// Session is a conversation persisted under the XDG data directory so it can
+// be resumed later.
+type Session struct {
+	ID        string               `json:"id"`
+	Title     string               `json:"title"`
+	Provider  models.ModelProvider `json:"provider"`
+	Model     models.ModelID       `json:"model"`
+	Directory string               `json:"directory"`
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+	Usage     provider.Usage       `json:"usage"`
+	Turns     []Turn               `json:"turns"`
+	Messages  model.ChatMessages   `json:"messages"`
+
+	// messageCount is the number of messages of a session listed without
+	// them.
+	messageCount int
+}
+
+// header is a session with its messages left undecoded, for listing.
+type header struct {
+	*Session
+	Messages []json.RawMessage `json:"messages"`
+}
+
+// Turn records the usage of a single turn for later reporting.
+type Turn struct {
+	Time     time.Time            `json:"time"`
+	Provider models.ModelProvider `json:"provider"`
+	Model    models.ModelID       `json:"model"`
+	Usage    provider.Usage       `json:"usage"`
+}
+
+var ErrNotFound = errors.New("session not found")
+
+const titleLength = 60
+
+func New(directory string, providerName models.ModelProvider, modelID models.ModelID) *Session {
+	now := time.Now()
+
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+
+	return &Session{
+		ID:        now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix),
+		Provider:  providerName,
+		Model:     modelID,
+		Directory: directory,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Messages:  model.ChatMessages{},
+	}
+}
+
+// Update records a finished turn. The title is taken from the first prompt.
+func (s *Session) Update(prompt string, messages model.ChatMessages, providerName models.ModelProvider, modelID models.ModelID, usage provider.Usage) {
+	now := time.Now()
+
+	if s.Title == "" {
+		s.Title = title(prompt)
+	}
+	s.Provider = providerName
+	s.Model = modelID
+	s.Messages = messages
+	s.Usage.Add(usage)
+	s.Turns = append(s.Turns, Turn{
+		Time:     now,
+		Provider: providerName,
+		Model:    modelID,
+		Usage:    usage,
+	})
+	s.UpdatedAt = now
+}
+
+// Save writes the session to a temporary file first, so an interrupted write
+// never leaves a truncated session behind.
+func (s *Session) Save() error {
+	path, err := xdg.DataFile(filepath.Join("kode", "sessions", s.ID+".json"))
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load returns the session with the given id, a unique prefix of it is enough.
+func Load(id string) (*Session, error) {
+	s, err := find(id)
+	if err != nil {
+		return nil, err
+	}
+	return read(s.ID)
+}
+
+// find returns the listed session with the given id or prefix of it.
+func find(id string) (*Session, error) {
+	sessions, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []*Session{}
+	for _, s := range sessions {
+		if s.ID == id {
+			return s, nil
+		}
+		if strings.HasPrefix(s.ID, id) {
+			matches = append(matches, s)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%s: %w", id, ErrNotFound)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("%s matches %d sessions", id, len(matches))
+	}
+}
+
+// Latest returns the most recently updated session started in directory.
+func Latest(directory string) (*Session, error) {
+	sessions, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range sessions {
+		if s.Directory == directory {
+			return read(s.ID)
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// List returns every saved session without its messages, most recently
+// updated first. Sessions that cannot be read are skipped with a warning, so
+// that a corrupt file does not hide the others.
+func List() ([]*Session, error) {
+	dir := directory()
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []*Session{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		content, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping session %s: %s\n", path, err)
+			continue
+		}
+
+		h := header{Session: &Session{}}
+		if err := json.Unmarshal(content, &h); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping session %s: %s\n", path, err)
+			continue
+		}
+		h.Session.messageCount = len(h.Messages)
+		sessions = append(sessions, h.Session)
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// MessageCount returns the number of messages, listed sessions included.
+func (s *Session) MessageCount() int {
+	if s.Messages == nil {
+		return s.messageCount
+	}
+	return len(s.Messages)
+}
+
+// read decodes the session with the given id in full.
+func read(id string) (*Session, error) {
+	path := filepath.Join(directory(), id+".json")
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{}
+	if err := json.Unmarshal(content, s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+func Remove(id string) (*Session, error) {
+	s, err := find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, os.Remove(filepath.Join(directory(), s.ID+".json"))
+}
+
+func directory() string {
+	return filepath.Join(xdg.DataHome, "kode", "sessions")
+}
+
+func title(prompt string) string {
+	title := strings.Join(strings.Fields(prompt), " ")
+	if len([]rune(title)) > titleLength {
+		title = string([]rune(title)[:titleLength-3]) + "..."
+	}
+	return title
+}
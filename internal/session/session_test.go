@@ -0,0 +1,97 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/provider"
+	"github.com/adrg/xdg"
+)
+
+func setup(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+}
+
+func save(t *testing.T, directory string, prompt string) *Session {
+	t.Helper()
+
+	s := New(directory, "mock", "mock")
+	messages := model.ChatMessages{
+		{Role: "user", Parts: []*model.ChatPart{{Type: "text", Text: prompt}, model.NewFile("image.png", []byte("\x89PNG"))}},
+		{Role: "assistant", Parts: []*model.ChatPart{{Type: "text", Text: "done"}}},
+	}
+	s.Update(prompt, messages, "mock", "mock", provider.Usage{Cost: 0.5})
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestList(t *testing.T) {
+	setup(t)
+
+	first := save(t, "/project", "first")
+	second := save(t, "/project", "second")
+
+	sessions, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != second.ID || sessions[1].ID != first.ID {
+		t.Fatalf("List() = %v, want the latest first", sessions)
+	}
+	if s := sessions[0]; s.Messages != nil || s.MessageCount() != 2 || s.Title != "second" || s.Usage.Cost != 0.5 {
+		t.Errorf("listed session = %+v, want its header and count alone", s)
+	}
+}
+
+func TestListSkipsCorruptSessions(t *testing.T) {
+	setup(t)
+
+	saved := save(t, "/project", "fine")
+	if err := os.WriteFile(filepath.Join(directory(), "20250101-000000-abcdef.json"), []byte(`{"id": "20250101-000000-abcdef", "messages": [`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != saved.ID {
+		t.Errorf("List() = %v, want the readable session alone", sessions)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	setup(t)
+
+	saved := save(t, "/project", "hello")
+	save(t, "/other", "elsewhere")
+
+	s, err := Load(saved.ID[:len(saved.ID)-2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Messages) != 2 || string(s.Messages[0].Parts[1].Data) != "\x89PNG" {
+		t.Errorf("loaded messages = %v, want them in full", s.Messages)
+	}
+
+	latest, err := Latest("/project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.ID != saved.ID || len(latest.Messages) != 2 {
+		t.Errorf("Latest() = %+v", latest)
+	}
+
+	if _, err := Load("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load(nope) = %v, want ErrNotFound", err)
+	}
+}
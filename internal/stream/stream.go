@@ -0,0 +1,127 @@
+package stream
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+	"github.com/TZGyn/kode/internal/provider"
+)
+
+const (
+	TypeUser       = "user"
+	TypeTextDelta  = "text-delta"
+	TypeToolCall   = "tool-call"
+	TypeToolResult = "tool-result"
+	TypeUsage      = "usage"
+	TypeCompaction = "compaction"
+	TypeFallback   = "fallback"
+	TypeWarning    = "warning"
+	TypeError      = "error"
+	TypeResult     = "result"
+)
+
+// Event is a line of the stream-json output. Messages and parts use the
+// model.ChatMessage and model.ChatPart schema. Failed tool calls and requests
+// are reported by an error event as they happen, the part of an error event
+// naming the failed call.
+type Event struct {
+	Type    string             `json:"type"`
+	Message *model.ChatMessage `json:"message,omitempty"`
+	Part    *model.ChatPart    `json:"part,omitempty"`
+	Usage   *provider.Usage    `json:"usage,omitempty"`
+	Error   string             `json:"error,omitempty"`
+	Warning string             `json:"warning,omitempty"`
+	// Provider and Model are the ones of the fallback model taking over.
+	Provider models.ModelProvider `json:"provider,omitempty"`
+	Model    models.ModelID       `json:"model,omitempty"`
+	Before   int64                `json:"before,omitempty"`
+	After    int64                `json:"after,omitempty"`
+	Result   *string              `json:"result,omitempty"`
+	IsError  bool                 `json:"is_error,omitempty"`
+}
+
+// Encoder writes events as JSON lines.
+type Encoder struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{encoder: json.NewEncoder(w)}
+}
+
+func (e *Encoder) Encode(event Event) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.encoder.Encode(event)
+}
+
+func (e *Encoder) User(message *model.ChatMessage) error {
+	return e.Encode(Event{Type: TypeUser, Message: message})
+}
+
+// Error reports the error a request failed with.
+func (e *Encoder) Error(err error) error {
+	return e.Encode(Event{Type: TypeError, Error: err.Error()})
+}
+
+// Result ends the stream with the final answer and the usage of the run, and
+// the error that ended it if any.
+func (e *Encoder) Result(result string, usage provider.Usage, err error) error {
+	event := Event{Type: TypeResult, Result: &result, Usage: &usage}
+	if err != nil {
+		event.IsError = true
+		event.Error = err.Error()
+	}
+	return e.Encode(event)
+}
+
+// Emit encodes a provider event, it can be used as a provider.EmitFunc.
+// Events without a stream counterpart are dropped.
+func (e *Encoder) Emit(event provider.Event) {
+	switch event := event.(type) {
+	case provider.TextDeltaEvent:
+		e.Encode(Event{
+			Type: TypeTextDelta,
+			Part: &model.ChatPart{Type: "text", Text: event.Text},
+		})
+	case provider.ToolCallEvent:
+		e.Encode(Event{
+			Type: TypeToolCall,
+			Part: &model.ChatPart{
+				Type:         "tool-call",
+				ToolCallID:   event.ID,
+				ToolCallName: event.Name,
+				ToolCallArgs: event.Args,
+			},
+		})
+	case provider.ToolCallEndEvent:
+		e.Encode(Event{
+			Type: TypeToolResult,
+			Part: &model.ChatPart{
+				Type:           "tool-result",
+				ToolCallID:     event.ID,
+				ToolCallName:   event.Name,
+				ToolCallResult: map[string]any{"result": event.Result},
+			},
+		})
+		if event.Err != nil {
+			e.Encode(Event{
+				Type:  TypeError,
+				Part:  &model.ChatPart{Type: "tool-call", ToolCallID: event.ID, ToolCallName: event.Name},
+				Error: event.Err.Error(),
+			})
+		}
+	case provider.UsageEvent:
+		e.Encode(Event{Type: TypeUsage, Usage: &event.Usage})
+	case provider.CompactionEvent:
+		e.Encode(Event{Type: TypeCompaction, Before: event.Before, After: event.After})
+	case provider.WarningEvent:
+		e.Encode(Event{Type: TypeWarning, Warning: event.Message})
+	case provider.FallbackEvent:
+		e.Encode(Event{Type: TypeFallback, Provider: event.Provider, Model: event.Model, Error: event.Err.Error()})
+	}
+}
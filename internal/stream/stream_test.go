@@ -0,0 +1,90 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/TZGyn/kode/internal/provider"
+)
+
+func decode(t *testing.T, output *bytes.Buffer) []Event {
+	t.Helper()
+
+	events := []Event{}
+	decoder := json.NewDecoder(output)
+	for decoder.More() {
+		event := Event{}
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestEmit(t *testing.T) {
+	output := &bytes.Buffer{}
+	encoder := NewEncoder(output)
+
+	encoder.Emit(provider.TextDeltaEvent{Text: "Hello"})
+	encoder.Emit(provider.StepEvent{Step: 2})
+	encoder.Emit(provider.ToolCallEvent{ID: "call_1", Name: "cat_file", Args: map[string]any{"filePath": "main.go"}})
+
+	events := decode(t, output)
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want the step dropped", len(events))
+	}
+	if events[0].Type != TypeTextDelta || events[0].Part.Text != "Hello" {
+		t.Errorf("events[0] = %+v", events[0])
+	}
+	if events[1].Type != TypeToolCall || events[1].Part.ToolCallArgs["filePath"] != "main.go" {
+		t.Errorf("events[1] = %+v", events[1])
+	}
+}
+
+func TestResult(t *testing.T) {
+	output := &bytes.Buffer{}
+	encoder := NewEncoder(output)
+
+	encoder.Result("partial answer", provider.Usage{InputTokens: 10}, errors.New("overloaded"))
+
+	events := decode(t, output)
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want the result alone", len(events))
+	}
+	result := events[0]
+	if result.Type != TypeResult || *result.Result != "partial answer" || !result.IsError || result.Error != "overloaded" {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+// TestErrors reports the failures as they happen, ahead of the result.
+func TestErrors(t *testing.T) {
+	output := &bytes.Buffer{}
+	encoder := NewEncoder(output)
+
+	encoder.Emit(provider.ToolCallEndEvent{ID: "call_1", Name: "run_command", Result: "Exit code: 1\n", Err: errors.New("command exited with code 1")})
+	encoder.Emit(provider.ToolCallEndEvent{ID: "call_2", Name: "cat_file", Result: "package main"})
+	encoder.Error(errors.New("overloaded"))
+	encoder.Result("", provider.Usage{}, errors.New("overloaded"))
+
+	events := decode(t, output)
+	types := []string{}
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	if want := []string{TypeToolResult, TypeError, TypeToolResult, TypeError, TypeResult}; !slices.Equal(types, want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+
+	toolError := events[1]
+	if toolError.Error != "command exited with code 1" || toolError.Part.ToolCallID != "call_1" || toolError.Part.ToolCallName != "run_command" {
+		t.Errorf("tool error = %+v, want the error of call_1", toolError)
+	}
+	if requestError := events[3]; requestError.Error != "overloaded" || requestError.Part != nil {
+		t.Errorf("request error = %+v", requestError)
+	}
+}
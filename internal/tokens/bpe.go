@@ -0,0 +1,208 @@
+package tokens
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/adrg/xdg"
+	"github.com/dlclark/regexp2"
+)
+
+const (
+	CL100K = "cl100k_base"
+	O200K  = "o200k_base"
+)
+
+var ErrVocabularyNotFound = errors.New("vocabulary not found")
+
+//go:generate go run gen_vocab.go
+
+// vocab holds the vocabularies shipped with kode, gzipped.
+//
+//go:embed vocab
+var vocab embed.FS
+
+// Pre-tokenization patterns of the tiktoken encodings, they need lookaheads
+// which regexp does not support.
+var patterns = map[string]string{
+	CL100K: `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`,
+	O200K: `[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+`,
+}
+
+// BPE is a byte pair encoding read from a tiktoken vocabulary. Special tokens
+// are not recognized, they are encoded as ordinary text.
+type BPE struct {
+	name    string
+	ranks   map[string]int
+	pattern *regexp2.Regexp
+}
+
+var (
+	loadedMu sync.Mutex
+	loaded   = map[string]*BPE{}
+)
+
+// Dir returns the directory vocabularies are read from before the embedded
+// ones, KODE_TOKENIZERS or the tokenizers directory of the kode data
+// directory. Vocabularies are the <encoding>.tiktoken files published with
+// tiktoken.
+func Dir() string {
+	if dir := os.Getenv("KODE_TOKENIZERS"); dir != "" {
+		return dir
+	}
+	return filepath.Join(xdg.DataHome, "kode", "tokenizers")
+}
+
+// LoadBPE returns the encoding, reading its vocabulary on first use.
+func LoadBPE(name string) (*BPE, error) {
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+
+	if bpe, ok := loaded[name]; ok {
+		return bpe, nil
+	}
+
+	pattern, ok := patterns[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+
+	ranks, err := loadRanks(name)
+	if err != nil {
+		return nil, err
+	}
+
+	bpe := &BPE{
+		name:    name,
+		ranks:   ranks,
+		pattern: regexp2.MustCompile(pattern, regexp2.None),
+	}
+	loaded[name] = bpe
+	return bpe, nil
+}
+
+// loadRanks reads the vocabulary of the encoding from Dir, or else from the
+// embedded ones.
+func loadRanks(name string) (map[string]int, error) {
+	path := filepath.Join(Dir(), name+".tiktoken")
+	file, err := os.Open(path)
+	if err == nil {
+		defer file.Close()
+		return readRanks(path, file)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	embedded, err := vocab.Open("vocab/" + name + ".tiktoken.gz")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrVocabularyNotFound, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer embedded.Close()
+
+	reader, err := gzip.NewReader(embedded)
+	if err != nil {
+		return nil, err
+	}
+	return readRanks(name, reader)
+}
+
+// readRanks parses a vocabulary, one base64 token and its rank per line.
+func readRanks(path string, reader io.Reader) (map[string]int, error) {
+	ranks := map[string]int{}
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		token, rank, ok := bytes.Cut(scanner.Bytes(), []byte(" "))
+		if !ok {
+			continue
+		}
+
+		decoded := make([]byte, base64.StdEncoding.DecodedLen(len(token)))
+		n, err := base64.StdEncoding.Decode(decoded, token)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		value, err := strconv.Atoi(string(rank))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		ranks[string(decoded[:n])] = value
+	}
+	return ranks, scanner.Err()
+}
+
+func (b *BPE) Name() string {
+	return b.name
+}
+
+func (b *BPE) Exact() bool {
+	return true
+}
+
+func (b *BPE) Count(text string) int {
+	return len(b.Encode(text))
+}
+
+// Encode returns the ranks of the tokens of text.
+func (b *BPE) Encode(text string) []int {
+	tokens := []int{}
+
+	match, _ := b.pattern.FindStringMatch(text)
+	for match != nil {
+		piece := match.String()
+		if rank, ok := b.ranks[piece]; ok {
+			tokens = append(tokens, rank)
+		} else {
+			tokens = append(tokens, b.merge([]byte(piece))...)
+		}
+		match, _ = b.pattern.FindNextMatch(match)
+	}
+
+	return tokens
+}
+
+// merge splits piece in bytes and repeatedly merges the adjacent pair with
+// the lowest rank, the way the vocabulary was built.
+func (b *BPE) merge(piece []byte) []int {
+	// bounds holds the start of every part, followed by the end of piece.
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+
+	for len(bounds) > 2 {
+		best, lowest := -1, math.MaxInt
+		for i := 0; i < len(bounds)-2; i++ {
+			if rank, ok := b.ranks[string(piece[bounds[i]:bounds[i+2]])]; ok && rank < lowest {
+				best, lowest = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+
+	tokens := make([]int, 0, len(bounds)-1)
+	for i := 0; i < len(bounds)-1; i++ {
+		tokens = append(tokens, b.ranks[string(piece[bounds[i]:bounds[i+1]])])
+	}
+	return tokens
+}
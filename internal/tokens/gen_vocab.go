@@ -0,0 +1,69 @@
+//go:build ignore
+
+// gen_vocab downloads the tiktoken vocabularies, checks them and writes them
+// gzipped to the vocab directory to be embedded.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+const baseURL = "https://openaipublic.blob.core.windows.net/encodings/"
+
+// hashes are the SHA-256 the tiktoken package checks the vocabularies with.
+var hashes = map[string]string{
+	"cl100k_base": "223921b76ee99bde995b7ff738513eef100fb51d18c93597a113bcffe865b2a7",
+	"o200k_base":  "446a9538cb6c348e3516120d7c08b09f57c36495e2acfffe59a5bf8b0cfb1a2d",
+}
+
+func main() {
+	for name, hash := range hashes {
+		if err := generate(name, hash); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func generate(name string, hash string) error {
+	response, err := http.Get(baseURL + name + ".tiktoken")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", name, response.Status)
+	}
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != hash {
+		return fmt.Errorf("%s: unexpected SHA-256 %x", name, sum)
+	}
+
+	compressed := &bytes.Buffer{}
+	writer, err := gzip.NewWriterLevel(compressed, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join("vocab", name+".tiktoken.gz"), compressed.Bytes(), 0o644)
+}
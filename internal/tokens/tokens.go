@@ -0,0 +1,94 @@
+package tokens
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/TZGyn/kode/internal/model"
+	"github.com/TZGyn/kode/internal/models"
+)
+
+const (
+	// messageOverhead is the number of tokens taken by the framing of a
+	// message, its role and delimiters.
+	messageOverhead = 4
+	// fileTokens is what an image or a document page roughly takes, they are
+	// not tokenized as text.
+	fileTokens = 1500
+)
+
+// Counter counts the tokens of a text for a model family, without network
+// access.
+type Counter interface {
+	Name() string
+	// Exact reports whether the counts come from the vocabulary of the model
+	// rather than an estimate.
+	Exact() bool
+	Count(text string) int
+}
+
+// ForModel returns the counter of the model. OpenAI models, on OpenAI or
+// Azure, are counted with their encoding, every other model is estimated.
+func ForModel(m models.Model) Counter {
+	switch m.Provider {
+	case models.ProviderOpenAI, models.ProviderAzure:
+		return Encoding(encoding(m))
+	case models.ProviderAnthropic, models.ProviderBedrock, models.ProviderVertexAnthropic:
+		return Anthropic
+	case models.ProviderGemini, models.ProviderVertex:
+		return Gemini
+	default:
+		return Encoding(CL100K)
+	}
+}
+
+// Encoding returns the BPE of the encoding, or its estimate when the
+// vocabulary is neither embedded nor installed.
+func Encoding(name string) Counter {
+	bpe, err := LoadBPE(name)
+	if err != nil {
+		return estimates[name]
+	}
+	return bpe
+}
+
+// encoding returns the tiktoken encoding of an OpenAI model, o200k for
+// gpt-4o and later, cl100k for gpt-4 and gpt-3.5.
+func encoding(m models.Model) string {
+	id := m.APIModel
+	if id == "" {
+		id = string(m.ID)
+	}
+
+	if strings.HasPrefix(id, "gpt-3.5") || (strings.HasPrefix(id, "gpt-4") &&
+		!strings.HasPrefix(id, "gpt-4o") && !strings.HasPrefix(id, "gpt-4.")) {
+		return CL100K
+	}
+	return O200K
+}
+
+// Messages returns the number of tokens messages take in a request.
+func Messages(counter Counter, messages model.ChatMessages) int64 {
+	total := 0
+	for _, message := range messages {
+		total += messageOverhead
+		for _, part := range message.Parts {
+			total += counter.Count(part.Text) + counter.Count(part.Reasoning)
+			if part.ToolCallArgs != nil {
+				args, _ := json.Marshal(part.ToolCallArgs)
+				total += counter.Count(part.ToolCallName) + counter.Count(string(args))
+			}
+			if part.ToolCallResult != nil {
+				total += counter.Count(part.ResultText())
+			}
+			if part.Type == "file" {
+				if part.IsText() {
+					total += counter.Count(string(part.Data))
+				} else {
+					total += fileTokens
+				}
+			}
+		}
+	}
+	return int64(total)
+}
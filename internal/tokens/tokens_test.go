@@ -0,0 +1,100 @@
+package tokens
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/dlclark/regexp2"
+)
+
+// references are cl100k counts published with tiktoken and its cookbook.
+var references = []struct {
+	text   string
+	tokens []int
+	count  int
+}{
+	{text: "hello world", tokens: []int{15339, 1917}, count: 2},
+	{text: "tiktoken is great!", tokens: []int{83, 1609, 5963, 374, 2294, 0}, count: 6},
+	{text: "antidisestablishmentarianism", count: 6},
+	{text: "2 + 2 = 4", count: 7},
+	{text: "お誕生日おめでとう", count: 9},
+	{text: "The quick brown fox jumps over the lazy dog.", count: 10},
+}
+
+// loadBPE loads the encoding from the embedded vocabularies only.
+func loadBPE(t *testing.T, name string) *BPE {
+	t.Helper()
+
+	t.Setenv("KODE_TOKENIZERS", t.TempDir())
+	ranks, err := loadRanks(name)
+	if err != nil {
+		t.Fatalf("%v, run go generate ./internal/tokens", err)
+	}
+	return &BPE{name: name, ranks: ranks, pattern: regexp2.MustCompile(patterns[name], regexp2.None)}
+}
+
+func TestCL100K(t *testing.T) {
+	bpe := loadBPE(t, CL100K)
+
+	for _, reference := range references {
+		tokens := bpe.Encode(reference.text)
+		if reference.tokens != nil && !slices.Equal(tokens, reference.tokens) {
+			t.Errorf("Encode(%q) = %v, want %v", reference.text, tokens, reference.tokens)
+		}
+		if len(tokens) != reference.count {
+			t.Errorf("Count(%q) = %d, want %d", reference.text, len(tokens), reference.count)
+		}
+	}
+}
+
+func TestO200K(t *testing.T) {
+	bpe := loadBPE(t, O200K)
+
+	if tokens := bpe.Encode("hello world"); !slices.Equal(tokens, []int{24912, 2375}) {
+		t.Errorf("Encode(hello world) = %v, want [24912 2375]", tokens)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	ranks := map[string]int{"ll": 256, "he": 257, "hell": 258, "hello": 259}
+	for i := range 256 {
+		ranks[string([]byte{byte(i)})] = i
+	}
+	bpe := &BPE{name: "test", ranks: ranks, pattern: regexp2.MustCompile(patterns[CL100K], regexp2.None)}
+
+	for text, want := range map[string][]int{
+		"hello":       {259},
+		"hellx":       {258, 'x'},
+		"ohell":       {'o', 258},
+		"hello hello": {259, ' ', 259},
+	} {
+		if tokens := bpe.Encode(text); !slices.Equal(tokens, want) {
+			t.Errorf("Encode(%q) = %v, want %v", text, tokens, want)
+		}
+	}
+}
+
+// TestEstimators bounds the estimates by the cl100k counts: Anthropic uses
+// more tokens than cl100k, Gemini about as many and fewer on other scripts.
+func TestEstimators(t *testing.T) {
+	for _, reference := range references {
+		anthropic := Anthropic.Count(reference.text)
+		if anthropic < reference.count || float64(anthropic) > 1.5*float64(reference.count) {
+			t.Errorf("Anthropic.Count(%q) = %d, want between %d and 1.5×", reference.text, anthropic, reference.count)
+		}
+
+		gemini := Gemini.Count(reference.text)
+		if float64(gemini) < 0.5*float64(reference.count) || float64(gemini) > 1.2*float64(reference.count) {
+			t.Errorf("Gemini.Count(%q) = %d, want between 0.5× and 1.2× %d", reference.text, gemini, reference.count)
+		}
+	}
+}
+
+func TestLoadRanksNotFound(t *testing.T) {
+	t.Setenv("KODE_TOKENIZERS", t.TempDir())
+
+	if _, err := loadRanks("unknown"); !errors.Is(err, ErrVocabularyNotFound) {
+		t.Errorf("loadRanks(unknown) = %v, want ErrVocabularyNotFound", err)
+	}
+}
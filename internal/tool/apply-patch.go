@@ -1,14 +1,589 @@
 package tool
 
-import "os/exec"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
-func ApplyPatch(patch string) (string, error) {
-	checkGitCmd := exec.Command("apply_patch", "--help")
+	"github.com/TZGyn/kode/internal/workspace"
+)
 
-	stdout, err := checkGitCmd.Output()
+const (
+	patchBegin     = "*** Begin Patch"
+	patchEnd       = "*** End Patch"
+	patchAddFile   = "*** Add File: "
+	patchDelete    = "*** Delete File: "
+	patchUpdate    = "*** Update File: "
+	patchMoveTo    = "*** Move to: "
+	patchEndOfFile = "*** End of File"
+)
+
+type ApplyPatchTool struct{}
+
+func (ApplyPatchTool) Name() string {
+	return "apply_patch"
+}
+
+func (ApplyPatchTool) Description() string {
+	return `Apply a patch to files in the repository. The patch must use this format:
+
+*** Begin Patch
+*** Add File: path/to/new.go
++line of the new file
+*** Delete File: path/to/old.go
+*** Update File: path/to/file.go
+*** Move to: path/to/renamed.go
+@@ func Example() {
+ context line
+-removed line
++added line
+ context line
+*** End Patch
+
+Paths are relative to the repository root. Every hunk of an update starts with @@, optionally followed by a line (such as a function signature) used to locate it. Include about 3 lines of context around each change, prefixed with a space. Use *** End of File after a hunk that must match the end of the file. A file can only appear once in a patch, and the patch is applied either entirely or not at all.`
+}
+
+func (ApplyPatchTool) Parameters() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"patch": {
+				Type:        "string",
+				Description: "the full patch text, from *** Begin Patch to *** End Patch",
+			},
+		},
+		Required: []string{"patch"},
+	}
+}
+
+func (ApplyPatchTool) Paths(args map[string]any) []string {
+	patch, _ := args["patch"].(string)
+	actions, err := parsePatch(patch)
+	if err != nil {
+		return nil
+	}
+
+	paths := []string{}
+	for _, action := range actions {
+		paths = append(paths, action.path)
+		if action.moveTo != "" {
+			paths = append(paths, action.moveTo)
+		}
+	}
+	return paths
+}
+
+func (ApplyPatchTool) Preview(ctx context.Context, args map[string]any) (string, error) {
+	patch, ok := args["patch"].(string)
+	if !ok {
+		return "", errors.New("missing patch")
+	}
+
+	changes, err := computePatch(workspace.FromContext(ctx), patch)
+	if err != nil {
+		return "", err
+	}
+
+	diffs := []string{}
+	for _, change := range changes {
+		diffs = append(diffs, change.diff())
+	}
+	return fileUpdateOutput(diffs...), nil
+}
+
+func (ApplyPatchTool) Execute(ctx context.Context, args map[string]any, response *string) (string, error) {
+	patch, ok := args["patch"].(string)
+	if !ok {
+		return "missing patch", errors.New("missing patch")
+	}
+
+	changes, err := applyPatch(workspace.FromContext(ctx), patch)
+	if err != nil {
+		return err.Error(), err
+	}
+
+	diffs := []string{}
+	for _, change := range changes {
+		diffs = append(diffs, change.diff())
+	}
+
+	*response = *response + fileUpdateOutput(diffs...)
+
+	return patchSummary(changes), nil
+}
+
+func ApplyPatch(ws *workspace.Workspace, patch string) (string, error) {
+	changes, err := applyPatch(ws, patch)
 	if err != nil {
-		return string(stdout), err
+		return "", err
 	}
 
-	return "File patch applied", nil
+	return patchSummary(changes), nil
+}
+
+type patchActionType int
+
+const (
+	patchActionAdd patchActionType = iota
+	patchActionDelete
+	patchActionUpdate
+)
+
+type patchAction struct {
+	kind   patchActionType
+	path   string
+	moveTo string
+	lines  []string
+	hunks  []*patchHunk
+}
+
+// patchHunk replaces the contiguous old lines with the new lines. Anchors are
+// the @@ lines that narrow down where the old lines are searched for. Context
+// holds for every new line the index of the old line it keeps, or -1, so that
+// context matched loosely is kept as it is in the file.
+type patchHunk struct {
+	anchors []string
+	old     []string
+	new     []string
+	context []int
+	eof     bool
+}
+
+// patchChange keeps the paths as written in the patch for display, and their
+// resolved counterparts for writing.
+type patchChange struct {
+	kind       patchActionType
+	path       string
+	moveTo     string
+	source     string
+	target     string
+	oldContent string
+	newContent string
+}
+
+func (c patchChange) diff() string {
+	from, to := "a/"+c.path, "b/"+c.path
+	switch c.kind {
+	case patchActionAdd:
+		from = "/dev/null"
+	case patchActionDelete:
+		to = "/dev/null"
+	}
+	if c.moveTo != "" {
+		to = "b/" + c.moveTo
+	}
+
+	return fileDiff(from, to, c.oldContent, c.newContent)
+}
+
+func patchSummary(changes []patchChange) string {
+	result := "Done!\n"
+	for _, change := range changes {
+		switch change.kind {
+		case patchActionAdd:
+			result += "A " + change.path + "\n"
+		case patchActionDelete:
+			result += "D " + change.path + "\n"
+		case patchActionUpdate:
+			if change.moveTo != "" {
+				result += "R " + change.path + " -> " + change.moveTo + "\n"
+			} else {
+				result += "M " + change.path + "\n"
+			}
+		}
+	}
+	return result
+}
+
+// applyPatch computes every change before writing, so a patch with a single
+// bad hunk leaves the tree untouched. When a write fails the changes written
+// so far are undone.
+func applyPatch(ws *workspace.Workspace, patch string) ([]patchChange, error) {
+	changes, err := computePatch(ws, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, change := range changes {
+		if err := change.write(); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				if undoErr := changes[j].undo(); undoErr != nil {
+					err = errors.Join(err, fmt.Errorf("could not undo the change to %s: %w", changes[j].path, undoErr))
+				}
+			}
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
+
+func computePatch(ws *workspace.Workspace, patch string) ([]patchChange, error) {
+	actions, err := parsePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []patchChange{}
+	for _, action := range actions {
+		change, err := action.change(ws)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+func parsePatch(patch string) ([]*patchAction, error) {
+	lines := strings.Split(strings.ReplaceAll(strings.TrimSpace(patch), "\r\n", "\n"), "\n")
+	if len(lines) < 2 || strings.TrimSpace(lines[0]) != patchBegin {
+		return nil, errors.New("invalid patch: must start with " + patchBegin)
+	}
+	if strings.TrimSpace(lines[len(lines)-1]) != patchEnd {
+		return nil, errors.New("invalid patch: must end with " + patchEnd)
+	}
+	lines = lines[1 : len(lines)-1]
+
+	actions := []*patchAction{}
+	seen := map[string]bool{}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		var action *patchAction
+
+		switch {
+		case strings.HasPrefix(line, patchAddFile):
+			action = &patchAction{kind: patchActionAdd, path: strings.TrimPrefix(line, patchAddFile)}
+		case strings.HasPrefix(line, patchDelete):
+			action = &patchAction{kind: patchActionDelete, path: strings.TrimPrefix(line, patchDelete)}
+		case strings.HasPrefix(line, patchUpdate):
+			action = &patchAction{kind: patchActionUpdate, path: strings.TrimPrefix(line, patchUpdate)}
+		default:
+			return nil, fmt.Errorf("invalid patch line %d: %q", i+2, line)
+		}
+		i++
+
+		action.path = strings.TrimSpace(action.path)
+		if err := validatePatchPath(action.path); err != nil {
+			return nil, err
+		}
+		if seen[action.path] {
+			return nil, fmt.Errorf("invalid patch: %s is changed twice", action.path)
+		}
+		seen[action.path] = true
+
+		body := []string{}
+		for i < len(lines) && !isPatchActionLine(lines[i]) {
+			body = append(body, lines[i])
+			i++
+		}
+		for len(body) > 0 && strings.TrimSpace(body[len(body)-1]) == "" {
+			body = body[:len(body)-1]
+		}
+
+		switch action.kind {
+		case patchActionAdd:
+			for _, bodyLine := range body {
+				if !strings.HasPrefix(bodyLine, "+") {
+					return nil, fmt.Errorf("invalid patch: added file %s has a line without +: %q", action.path, bodyLine)
+				}
+				action.lines = append(action.lines, bodyLine[1:])
+			}
+		case patchActionDelete:
+			if len(body) > 0 {
+				return nil, fmt.Errorf("invalid patch: unexpected content after deleting %s", action.path)
+			}
+		case patchActionUpdate:
+			if len(body) > 0 && strings.HasPrefix(body[0], patchMoveTo) {
+				action.moveTo = strings.TrimSpace(strings.TrimPrefix(body[0], patchMoveTo))
+				if err := validatePatchPath(action.moveTo); err != nil {
+					return nil, err
+				}
+				if seen[action.moveTo] {
+					return nil, fmt.Errorf("invalid patch: %s is changed twice", action.moveTo)
+				}
+				seen[action.moveTo] = true
+				body = body[1:]
+			}
+
+			hunks, err := parseHunks(body)
+			if err != nil {
+				return nil, fmt.Errorf("invalid patch for %s: %w", action.path, err)
+			}
+			if len(hunks) == 0 && action.moveTo == "" {
+				return nil, fmt.Errorf("invalid patch: update of %s has no hunks", action.path)
+			}
+			action.hunks = hunks
+		}
+
+		actions = append(actions, action)
+	}
+
+	return actions, nil
+}
+
+func isPatchActionLine(line string) bool {
+	return strings.HasPrefix(line, patchAddFile) ||
+		strings.HasPrefix(line, patchDelete) ||
+		strings.HasPrefix(line, patchUpdate)
+}
+
+func validatePatchPath(path string) error {
+	if path == "" {
+		return errors.New("invalid patch: empty path")
+	}
+	if filepath.IsAbs(path) {
+		return fmt.Errorf("invalid patch: %s must be relative to the repository root", path)
+	}
+	return nil
+}
+
+func parseHunks(body []string) ([]*patchHunk, error) {
+	hunks := []*patchHunk{}
+	var current *patchHunk
+
+	for _, line := range body {
+		if line == patchEndOfFile {
+			if current == nil {
+				return nil, errors.New(patchEndOfFile + " outside of a hunk")
+			}
+			current.eof = true
+			continue
+		}
+
+		if strings.HasPrefix(line, "@@") {
+			anchor := strings.TrimSpace(strings.TrimPrefix(line, "@@"))
+			if current == nil || len(current.old) > 0 || len(current.new) > 0 {
+				current = &patchHunk{}
+				hunks = append(hunks, current)
+			}
+			if anchor != "" {
+				current.anchors = append(current.anchors, anchor)
+			}
+			continue
+		}
+
+		if current == nil {
+			current = &patchHunk{}
+			hunks = append(hunks, current)
+		}
+		if current.eof {
+			return nil, errors.New("hunk continues after " + patchEndOfFile)
+		}
+
+		switch {
+		case line == "" || line[0] == ' ':
+			current.context = append(current.context, len(current.old))
+			current.old = append(current.old, strings.TrimPrefix(line, " "))
+			current.new = append(current.new, strings.TrimPrefix(line, " "))
+		case line[0] == '-':
+			current.old = append(current.old, line[1:])
+		case line[0] == '+':
+			current.context = append(current.context, -1)
+			current.new = append(current.new, line[1:])
+		default:
+			return nil, fmt.Errorf("line must start with ' ', '-' or '+': %q", line)
+		}
+	}
+
+	return hunks, nil
+}
+
+func (a *patchAction) change(ws *workspace.Workspace) (patchChange, error) {
+	change := patchChange{kind: a.kind, path: a.path, moveTo: a.moveTo}
+
+	source, err := ws.Resolve(a.path)
+	if err != nil {
+		return change, err
+	}
+	change.source, change.target = source, source
+	if a.moveTo != "" {
+		change.target, err = ws.Resolve(a.moveTo)
+		if err != nil {
+			return change, err
+		}
+	}
+
+	switch a.kind {
+	case patchActionAdd:
+		if _, err := os.Stat(change.source); err == nil {
+			return change, fmt.Errorf("cannot add %s: file already exists", a.path)
+		}
+		change.newContent = strings.Join(a.lines, "\n") + "\n"
+	case patchActionDelete:
+		content, err := os.ReadFile(change.source)
+		if err != nil {
+			return change, err
+		}
+		change.oldContent = string(content)
+	case patchActionUpdate:
+		content, err := os.ReadFile(change.source)
+		if err != nil {
+			return change, err
+		}
+		change.oldContent = string(content)
+
+		newContent, err := applyHunks(change.oldContent, a.hunks)
+		if err != nil {
+			return change, fmt.Errorf("cannot update %s: %w", a.path, err)
+		}
+		change.newContent = newContent
+
+		if a.moveTo != "" {
+			if _, err := os.Stat(change.target); err == nil {
+				return change, fmt.Errorf("cannot move %s: %s already exists", a.path, a.moveTo)
+			}
+		}
+	}
+
+	return change, nil
+}
+
+func (c patchChange) write() error {
+	switch c.kind {
+	case patchActionAdd:
+		return writePatchFile(c.target, c.newContent)
+	case patchActionDelete:
+		return os.Remove(c.source)
+	case patchActionUpdate:
+		if err := writePatchFile(c.target, c.newContent); err != nil {
+			return err
+		}
+		if c.target == c.source {
+			return nil
+		}
+		if err := os.Remove(c.source); err != nil {
+			os.Remove(c.target)
+			return err
+		}
+	}
+	return nil
+}
+
+// undo restores the files as they were before write.
+func (c patchChange) undo() error {
+	switch c.kind {
+	case patchActionAdd:
+		return os.Remove(c.target)
+	case patchActionDelete:
+		return writePatchFile(c.source, c.oldContent)
+	case patchActionUpdate:
+		if err := writePatchFile(c.source, c.oldContent); err != nil {
+			return err
+		}
+		if c.target == c.source {
+			return nil
+		}
+		return os.Remove(c.target)
+	}
+	return nil
+}
+
+func writePatchFile(path string, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|oNoFollow, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func applyHunks(content string, hunks []*patchHunk) (string, error) {
+	trailingNewline := strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = []string{}
+	}
+
+	cursor := 0
+	for i, hunk := range hunks {
+		for _, anchor := range hunk.anchors {
+			index := findPatchLines(lines, []string{anchor}, cursor, false)
+			if index < 0 {
+				return "", fmt.Errorf("hunk %d: could not find @@ %s", i+1, anchor)
+			}
+			cursor = index + 1
+		}
+
+		index := cursor
+		switch {
+		case len(hunk.old) > 0:
+			index = findPatchLines(lines, hunk.old, cursor, hunk.eof)
+		case hunk.eof:
+			index = len(lines)
+		}
+		if index < 0 {
+			return "", fmt.Errorf("hunk %d: could not find the lines to replace:\n%s", i+1, strings.Join(hunk.old, "\n"))
+		}
+
+		updated := append([]string{}, lines[:index]...)
+		for j, line := range hunk.new {
+			if hunk.context[j] >= 0 {
+				line = lines[index+hunk.context[j]]
+			}
+			updated = append(updated, line)
+		}
+		updated = append(updated, lines[index+len(hunk.old):]...)
+		lines = updated
+
+		cursor = index + len(hunk.new)
+	}
+
+	result := strings.Join(lines, "\n")
+	if trailingNewline || content == "" {
+		result += "\n"
+	}
+	return result, nil
+}
+
+// findPatchLines looks for target in lines at or after start, first exactly,
+// then ignoring trailing whitespace and finally ignoring all surrounding
+// whitespace. With eof the match must end at the last line.
+func findPatchLines(lines []string, target []string, start int, eof bool) int {
+	normalizers := []func(string) string{
+		func(s string) string { return s },
+		func(s string) string { return strings.TrimRight(s, " \t") },
+		strings.TrimSpace,
+	}
+
+	for _, normalize := range normalizers {
+		if eof {
+			index := len(lines) - len(target)
+			if index >= start && matchPatchLines(lines[index:], target, normalize) {
+				return index
+			}
+			continue
+		}
+
+		for index := start; index+len(target) <= len(lines); index++ {
+			if matchPatchLines(lines[index:index+len(target)], target, normalize) {
+				return index
+			}
+		}
+	}
+
+	return -1
+}
+
+func matchPatchLines(lines []string, target []string, normalize func(string) string) bool {
+	if len(lines) != len(target) {
+		return false
+	}
+	for i := range target {
+		if normalize(lines[i]) != normalize(target[i]) {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,272 @@
+package tool
+
+import (
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TZGyn/kode/internal/workspace"
+)
+
+// setupFiles returns a workspace rooted in a temporary directory holding the
+// files.
+func setupFiles(t *testing.T, files map[string]string) *workspace.Workspace {
+	t.Helper()
+
+	root := t.TempDir()
+	for path, content := range files {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ws, err := workspace.New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+// readFiles returns the content of every file of the workspace.
+func readFiles(t *testing.T, ws *workspace.Workspace) map[string]string {
+	t.Helper()
+
+	files := map[string]string{}
+	err := filepath.WalkDir(ws.Root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(ws.Root, path)
+		files[filepath.ToSlash(relative)] = string(content)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func patch(lines ...string) string {
+	return "*** Begin Patch\n" + strings.Join(lines, "\n") + "\n*** End Patch"
+}
+
+const mainGo = `package main
+
+import "fmt"
+
+func first() {
+	fmt.Println("same")
+}
+
+func second() {
+	fmt.Println("same")
+}
+`
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		patch string
+		want  map[string]string
+	}{
+		{
+			name:  "add",
+			files: map[string]string{},
+			patch: patch("*** Add File: dir/new.go", "+package dir", "+", "+var x = 1"),
+			want:  map[string]string{"dir/new.go": "package dir\n\nvar x = 1\n"},
+		},
+		{
+			name:  "delete",
+			files: map[string]string{"old.go": "package old\n", "keep.go": "package keep\n"},
+			patch: patch("*** Delete File: old.go"),
+			want:  map[string]string{"keep.go": "package keep\n"},
+		},
+		{
+			name:  "update",
+			files: map[string]string{"a.txt": "one\ntwo\nthree\nfour\n"},
+			patch: patch("*** Update File: a.txt", "@@", " one", "-two", "+2", " three"),
+			want:  map[string]string{"a.txt": "one\n2\nthree\nfour\n"},
+		},
+		{
+			name:  "update with several hunks",
+			files: map[string]string{"a.txt": "a\nb\nc\nd\ne\nf\n"},
+			patch: patch("*** Update File: a.txt", "@@", " a", "-b", "+B", "@@", " e", "-f", "+F"),
+			want:  map[string]string{"a.txt": "a\nB\nc\nd\ne\nF\n"},
+		},
+		{
+			name:  "update without a trailing newline",
+			files: map[string]string{"a.txt": "one\ntwo"},
+			patch: patch("*** Update File: a.txt", "@@", "-two", "+2"),
+			want:  map[string]string{"a.txt": "one\n2"},
+		},
+		{
+			name:  "move with a hunk",
+			files: map[string]string{"old/a.txt": "one\ntwo\n"},
+			patch: patch("*** Update File: old/a.txt", "*** Move to: new/b.txt", "@@", " one", "-two", "+2"),
+			want:  map[string]string{"new/b.txt": "one\n2\n"},
+		},
+		{
+			name:  "move only",
+			files: map[string]string{"a.txt": "one\n"},
+			patch: patch("*** Update File: a.txt", "*** Move to: b.txt"),
+			want:  map[string]string{"b.txt": "one\n"},
+		},
+		{
+			name:  "anchor",
+			files: map[string]string{"main.go": mainGo},
+			patch: patch("*** Update File: main.go", "@@ func second() {", `-	fmt.Println("same")`, `+	fmt.Println("second")`),
+			want:  map[string]string{"main.go": strings.Replace(mainGo, "func second() {\n\tfmt.Println(\"same\")", "func second() {\n\tfmt.Println(\"second\")", 1)},
+		},
+		{
+			name:  "nested anchors",
+			files: map[string]string{"a.txt": "class A\n  def run\n    x\nclass B\n  def run\n    x\n"},
+			patch: patch("*** Update File: a.txt", "@@ class B", "@@   def run", "-    x", "+    y"),
+			want:  map[string]string{"a.txt": "class A\n  def run\n    x\nclass B\n  def run\n    y\n"},
+		},
+		{
+			name:  "end of file",
+			files: map[string]string{"a.txt": "}\nmiddle\n}\n"},
+			patch: patch("*** Update File: a.txt", "@@", "-}", "+end", "*** End of File"),
+			want:  map[string]string{"a.txt": "}\nmiddle\nend\n"},
+		},
+		{
+			name:  "append at the end of file",
+			files: map[string]string{"a.txt": "one\n"},
+			patch: patch("*** Update File: a.txt", "@@", "+two", "*** End of File"),
+			want:  map[string]string{"a.txt": "one\ntwo\n"},
+		},
+		{
+			name:  "context with trailing whitespace",
+			files: map[string]string{"a.txt": "one  \ntwo\t\nthree\n"},
+			patch: patch("*** Update File: a.txt", "@@", " one", "-two", "+2", " three"),
+			want:  map[string]string{"a.txt": "one  \n2\nthree\n"},
+		},
+		{
+			name:  "context with other indentation",
+			files: map[string]string{"a.go": "func f() {\n\t\tx := 1\n\t\treturn x\n}\n"},
+			patch: patch("*** Update File: a.go", "@@", "     x := 1", "-    return x", "+\t\treturn x + 1"),
+			want:  map[string]string{"a.go": "func f() {\n\t\tx := 1\n\t\treturn x + 1\n}\n"},
+		},
+		{
+			name:  "CRLF patch",
+			files: map[string]string{"a.txt": "one\ntwo\n"},
+			patch: strings.ReplaceAll(patch("*** Update File: a.txt", "@@", "-two", "+2"), "\n", "\r\n"),
+			want:  map[string]string{"a.txt": "one\n2\n"},
+		},
+		{
+			name:  "several files",
+			files: map[string]string{"a.txt": "a\n", "b.txt": "b\n"},
+			patch: patch("*** Add File: c.txt", "+c", "*** Delete File: b.txt", "*** Update File: a.txt", "@@", "-a", "+A"),
+			want:  map[string]string{"a.txt": "A\n", "c.txt": "c\n"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ws := setupFiles(t, test.files)
+
+			if _, err := ApplyPatch(ws, test.patch); err != nil {
+				t.Fatal(err)
+			}
+			if files := readFiles(t, ws); !maps.Equal(files, test.want) {
+				t.Errorf("files = %q, want %q", files, test.want)
+			}
+		})
+	}
+}
+
+func TestApplyPatchInvalid(t *testing.T) {
+	files := map[string]string{"a.txt": "one\ntwo\n", "b.txt": "b\n"}
+
+	tests := []struct {
+		name  string
+		patch string
+		err   string
+	}{
+		{name: "no begin", patch: "*** Update File: a.txt\n*** End Patch", err: "must start with"},
+		{name: "no end", patch: "*** Begin Patch\n*** Delete File: a.txt", err: "must end with"},
+		{name: "unknown action", patch: patch("*** Rename File: a.txt"), err: "invalid patch line 2"},
+		{name: "added line without +", patch: patch("*** Add File: c.txt", "+one", "two"), err: "line without +"},
+		{name: "content after delete", patch: patch("*** Delete File: a.txt", "-one"), err: "unexpected content"},
+		{name: "update without hunks", patch: patch("*** Update File: a.txt"), err: "has no hunks"},
+		{name: "hunk line without prefix", patch: patch("*** Update File: a.txt", "@@", "-one", "*two"), err: "must start with"},
+		{name: "end of file outside of a hunk", patch: patch("*** Update File: a.txt", "*** End of File"), err: "outside of a hunk"},
+		{name: "hunk after end of file", patch: patch("*** Update File: a.txt", "@@", "-two", "*** End of File", "+three"), err: "continues after"},
+		{name: "empty path", patch: patch("*** Delete File: "), err: "empty path"},
+		{name: "absolute path", patch: patch("*** Add File: " + filepath.Join(string(filepath.Separator), "tmp", "c.txt"), "+c"), err: "relative"},
+		{name: "outside of the workspace", patch: patch("*** Add File: ../c.txt", "+c"), err: "outside"},
+		{name: "changed twice", patch: patch("*** Delete File: a.txt", "*** Update File: a.txt", "@@", "-one"), err: "a.txt is changed twice"},
+		{name: "move onto an added file", patch: patch("*** Add File: c.txt", "+c", "*** Update File: a.txt", "*** Move to: c.txt"), err: "c.txt is changed twice"},
+		{name: "move onto an updated file", patch: patch("*** Update File: a.txt", "*** Move to: b.txt", "*** Update File: b.txt", "@@", "-b", "+B"), err: "b.txt is changed twice"},
+		{name: "two moves onto a file", patch: patch("*** Update File: a.txt", "*** Move to: c.txt", "*** Update File: b.txt", "*** Move to: c.txt"), err: "c.txt is changed twice"},
+		{name: "move onto an existing file", patch: patch("*** Update File: a.txt", "*** Move to: b.txt"), err: "already exists"},
+		{name: "add an existing file", patch: patch("*** Add File: a.txt", "+a"), err: "already exists"},
+		{name: "delete a missing file", patch: patch("*** Delete File: c.txt"), err: "c.txt"},
+		{name: "context not found", patch: patch("*** Add File: c.txt", "+c", "*** Update File: a.txt", "@@", "-three", "+3"), err: "could not find the lines"},
+		{name: "anchor not found", patch: patch("*** Update File: a.txt", "@@ func missing()", "-one"), err: "could not find @@ func missing()"},
+		{name: "not at the end of file", patch: patch("*** Update File: a.txt", "@@", "-one", "*** End of File"), err: "could not find the lines"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ws := setupFiles(t, files)
+
+			_, err := ApplyPatch(ws, test.patch)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("ApplyPatch = %v, want an error containing %q", err, test.err)
+			}
+			if after := readFiles(t, ws); !maps.Equal(after, files) {
+				t.Errorf("files = %q, want them untouched", after)
+			}
+		})
+	}
+}
+
+// TestApplyPatchRollback fails the last write of a patch, adding a file where
+// an earlier change created a directory, the writes before must be undone.
+func TestApplyPatchRollback(t *testing.T) {
+	files := map[string]string{"a.txt": "one\n", "b.txt": "b\n"}
+	ws := setupFiles(t, files)
+
+	_, err := ApplyPatch(ws, patch(
+		"*** Update File: a.txt", "*** Move to: moved.txt", "@@", "-one", "+1",
+		"*** Delete File: b.txt",
+		"*** Add File: dir/c.txt", "+c",
+		"*** Add File: dir", "+d",
+	))
+	if err == nil {
+		t.Fatal("ApplyPatch succeeded writing a file over a directory")
+	}
+	if after := readFiles(t, ws); !maps.Equal(after, files) {
+		t.Errorf("files = %q, want them restored", after)
+	}
+}
+
+func TestApplyPatchPreview(t *testing.T) {
+	files := map[string]string{"a.txt": "one\ntwo\n"}
+	ws := setupFiles(t, files)
+	ctx := workspace.NewContext(t.Context(), ws)
+
+	preview, err := ApplyPatchTool{}.Preview(ctx, map[string]any{"patch": patch("*** Update File: a.txt", "@@", "-two", "+2")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(preview, "-two") || !strings.Contains(preview, "+2") {
+		t.Errorf("preview = %q, want the diff", preview)
+	}
+	if after := readFiles(t, ws); !maps.Equal(after, files) {
+		t.Errorf("files = %q, want them untouched by the preview", after)
+	}
+}
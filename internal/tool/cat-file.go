@@ -1,18 +1,71 @@
 package tool
 
 import (
+	"context"
 	"os"
+
+	"github.com/TZGyn/kode/internal/workspace"
 )
 
-func CatFile(filePath string) (string, error) {
+type CatFileTool struct{}
+
+func (CatFileTool) Name() string {
+	return "cat_file"
+}
+
+func (CatFileTool) Description() string {
+	return "Given a file path, return all its content as string"
+}
+
+func (CatFileTool) Parameters() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"filePath": {
+				Type:        "string",
+				Description: "the file path to output relative to root",
+			},
+		},
+		Required: []string{"filePath"},
+	}
+}
+
+func (CatFileTool) Paths(args map[string]any) []string {
+	filePath, _ := args["filePath"].(string)
+	return []string{filePath}
+}
+
+func (CatFileTool) Execute(ctx context.Context, args map[string]any, response *string) (string, error) {
 	result := ""
 
-	// stat, err := os.Stat("./" + filePath)
-	// if err != nil {
-	// 	return result, err
-	// }
+	filePath, ok := args["filePath"].(string)
+	if ok {
+		path, err := workspace.FromContext(ctx).ResolveReadable(filePath)
+		if err != nil {
+			return err.Error(), err
+		}
+
+		content, err := CatFile(path)
+		if err != nil {
+			return err.Error(), err
+		}
+		result = content
+
+		toolResult := ""
+		toolResult += "## File content " + filePath + "\n"
+		toolResult += result + "\n"
+		toolResult += "## File content\n"
+
+		*response = *response + toolResult
+	}
+
+	return result, nil
+}
+
+func CatFile(filePath string) (string, error) {
+	result := ""
 
-	file, err := os.ReadFile("./" + filePath)
+	file, err := os.ReadFile(filePath)
 	if err != nil {
 		return result, err
 	}
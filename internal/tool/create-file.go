@@ -1,13 +1,80 @@
 package tool
 
-import "os"
+import (
+	"context"
+	"errors"
+	"os"
 
-func CreateFile(path string) error {
-	_, err := os.Create(path)
+	"github.com/TZGyn/kode/internal/workspace"
+)
+
+type CreateFileTool struct{}
+
+func (CreateFileTool) Name() string {
+	return "create_file"
+}
+
+func (CreateFileTool) Description() string {
+	return "Given a file path, create a empty file in the path"
+}
+
+func (CreateFileTool) Parameters() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"filePath": {
+				Type:        "string",
+				Description: "the file path to create relative to root",
+			},
+		},
+		Required: []string{"filePath"},
+	}
+}
+
+func (CreateFileTool) Paths(args map[string]any) []string {
+	path, _ := args["filePath"].(string)
+	return []string{path}
+}
 
+func (CreateFileTool) Preview(ctx context.Context, args map[string]any) (string, error) {
+	path, ok := args["filePath"].(string)
+	if !ok {
+		return "", errors.New("missing filePath")
+	}
+	return "Create empty file `" + path + "`\n", nil
+}
+
+func (CreateFileTool) Execute(ctx context.Context, args map[string]any, response *string) (string, error) {
+	result := ""
+	path, ok := args["filePath"].(string)
+	if ok {
+		resolved, err := workspace.FromContext(ctx).Resolve(path)
+		if err != nil {
+			return err.Error(), err
+		}
+
+		err = CreateFile(resolved)
+		if err != nil {
+			return err.Error(), err
+		}
+		result = "File Created Successfully"
+
+		toolResult := ""
+		toolResult += "## File create\n"
+		toolResult += path + "\n"
+		toolResult += "## File create\n"
+
+		*response = *response + toolResult
+	}
+
+	return result, nil
+}
+
+func CreateFile(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC|oNoFollow, 0o666)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return f.Close()
 }
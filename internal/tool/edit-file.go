@@ -0,0 +1,331 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/TZGyn/kode/internal/workspace"
+)
+
+type EditFileTool struct{}
+
+func (EditFileTool) Name() string {
+	return "edit_file"
+}
+
+func (EditFileTool) Description() string {
+	return "Edit a file by replacing snippets of it. Each old_string must match a unique part of the file, include enough surrounding lines to make it unique. Prefer this over update_file for changes to existing files"
+}
+
+func (EditFileTool) Parameters() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"path": {
+				Type:        "string",
+				Description: "Path of the file",
+			},
+			"edits": {
+				Type:        "array",
+				Description: "Replacements applied in order",
+				Items: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"old_string": {
+							Type:        "string",
+							Description: "Existing text to replace",
+						},
+						"new_string": {
+							Type:        "string",
+							Description: "Text to replace it with",
+						},
+						"replace_all": {
+							Type:        "boolean",
+							Description: "Replace every exact occurrence instead of requiring a unique match",
+						},
+					},
+					Required: []string{"old_string", "new_string"},
+				},
+			},
+		},
+		Required: []string{"path", "edits"},
+	}
+}
+
+func (EditFileTool) Paths(args map[string]any) []string {
+	path, _ := args["path"].(string)
+	return []string{path}
+}
+
+func (EditFileTool) Preview(ctx context.Context, args map[string]any) (string, error) {
+	path, _, before, after, err := editFile(ctx, args)
+	if err != nil {
+		return "", err
+	}
+
+	return fileUpdateOutput(fileDiff("a/"+path, "b/"+path, before, after)), nil
+}
+
+func (EditFileTool) Execute(ctx context.Context, args map[string]any, response *string) (string, error) {
+	path, resolved, before, after, err := editFile(ctx, args)
+	if err != nil {
+		return err.Error(), err
+	}
+
+	_, err = UpdateFile(resolved, after)
+	if err != nil {
+		return err.Error(), err
+	}
+
+	*response = *response + fileUpdateOutput(fileDiff("a/"+path, "b/"+path, before, after))
+
+	edits, _ := args["edits"].([]any)
+	return fmt.Sprintf("File updated, %d edits applied", len(edits)), nil
+}
+
+// editFile resolves the file and computes its content once every edit is
+// applied.
+func editFile(ctx context.Context, args map[string]any) (path string, resolved string, before string, after string, err error) {
+	path, ok := args["path"].(string)
+	if !ok {
+		return "", "", "", "", errors.New("missing path")
+	}
+
+	edits, err := parseEdits(args["edits"])
+	if err != nil {
+		return "", "", "", "", err
+	}
+
+	resolved, err = workspace.FromContext(ctx).Resolve(path)
+	if err != nil {
+		return "", "", "", "", err
+	}
+
+	file, err := os.ReadFile(resolved)
+	if err != nil {
+		return "", "", "", "", err
+	}
+
+	content := string(file)
+	for i, edit := range edits {
+		content, err = applyEdit(content, edit)
+		if err != nil {
+			return "", "", "", "", fmt.Errorf("edit %d failed, no changes were written: %w", i+1, err)
+		}
+	}
+
+	return path, resolved, string(file), content, nil
+}
+
+type fileEdit struct {
+	oldString  string
+	newString  string
+	replaceAll bool
+}
+
+func parseEdits(value any) ([]fileEdit, error) {
+	items, ok := value.([]any)
+	if !ok || len(items) == 0 {
+		return nil, errors.New("edits must be a non empty list")
+	}
+
+	edits := []fileEdit{}
+	for i, item := range items {
+		fields, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("edit %d must be an object", i+1)
+		}
+
+		oldString, oldOk := fields["old_string"].(string)
+		newString, newOk := fields["new_string"].(string)
+		if !oldOk || !newOk {
+			return nil, fmt.Errorf("edit %d needs old_string and new_string", i+1)
+		}
+		if oldString == "" {
+			return nil, fmt.Errorf("edit %d has an empty old_string", i+1)
+		}
+		replaceAll, _ := fields["replace_all"].(bool)
+
+		edits = append(edits, fileEdit{
+			oldString:  oldString,
+			newString:  newString,
+			replaceAll: replaceAll,
+		})
+	}
+
+	return edits, nil
+}
+
+// applyEdit replaces edit.oldString in content. Exact matches are tried first,
+// then line based matches ignoring trailing whitespace, then ignoring
+// indentation, in which case newString is re-indented to the matched lines.
+func applyEdit(content string, edit fileEdit) (string, error) {
+	count := strings.Count(content, edit.oldString)
+	if count == 1 || (count > 1 && edit.replaceAll) {
+		return strings.ReplaceAll(content, edit.oldString, edit.newString), nil
+	}
+
+	lines := strings.Split(content, "\n")
+	oldLines := strings.Split(strings.TrimSuffix(edit.oldString, "\n"), "\n")
+	newLines := strings.Split(strings.TrimSuffix(edit.newString, "\n"), "\n")
+
+	if count > 1 {
+		matches := []int{}
+		for offset := 0; ; {
+			index := strings.Index(content[offset:], edit.oldString)
+			if index < 0 {
+				break
+			}
+			matches = append(matches, strings.Count(content[:offset+index], "\n"))
+			offset += index + len(edit.oldString)
+		}
+		return "", ambiguousEditError(lines, matches)
+	}
+
+	trimRight := func(s string) string { return strings.TrimRight(s, " \t\r") }
+
+	matches := findEditLines(lines, oldLines, trimRight)
+	if len(matches) == 1 {
+		return replaceEditLines(lines, matches[0], len(oldLines), newLines), nil
+	}
+	if len(matches) > 1 {
+		return "", ambiguousEditError(lines, matches)
+	}
+
+	matches = findEditLines(lines, oldLines, strings.TrimSpace)
+	if len(matches) == 1 {
+		index := matches[0]
+		newLines = reindentEditLines(newLines, oldLines, lines[index:index+len(oldLines)])
+		return replaceEditLines(lines, index, len(oldLines), newLines), nil
+	}
+	if len(matches) > 1 {
+		return "", ambiguousEditError(lines, matches)
+	}
+
+	return "", missingEditError(lines, oldLines)
+}
+
+func findEditLines(lines []string, target []string, normalize func(string) string) []int {
+	matches := []int{}
+	for index := 0; index+len(target) <= len(lines); index++ {
+		if matchPatchLines(lines[index:index+len(target)], target, normalize) {
+			matches = append(matches, index)
+		}
+	}
+	return matches
+}
+
+func replaceEditLines(lines []string, index int, count int, newLines []string) string {
+	updated := append([]string{}, lines[:index]...)
+	updated = append(updated, newLines...)
+	updated = append(updated, lines[index+count:]...)
+	return strings.Join(updated, "\n")
+}
+
+func indentation(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// reindentEditLines adapts the indentation of lines written against oldLines
+// to the one found in the file. The first line whose indentation differs
+// decides the mapping: a tab/space conversion when both sides only use one
+// kind of character, otherwise a plain prefix substitution.
+func reindentEditLines(lines []string, oldLines []string, fileLines []string) []string {
+	from, to := "", ""
+	for i, line := range oldLines {
+		if strings.TrimSpace(line) != "" && indentation(line) != indentation(fileLines[i]) {
+			from, to = indentation(line), indentation(fileLines[i])
+			break
+		}
+	}
+	if from == "" && to == "" {
+		return lines
+	}
+
+	convert := func(indent string) string {
+		if strings.HasPrefix(indent, from) {
+			return to + strings.TrimPrefix(indent, from)
+		}
+		return indent
+	}
+
+	fromUnit, toUnit := strings.Trim(from, " ") == "", strings.Trim(to, "\t") == ""
+	if from != "" && to != "" && fromUnit && toUnit && len(from)%len(to) == 0 {
+		width := len(from) / len(to)
+		convert = func(indent string) string {
+			spaces := len(indent) - len(strings.TrimLeft(indent, " "))
+			return strings.Repeat("\t", spaces/width) + strings.Repeat(" ", spaces%width) + indent[spaces:]
+		}
+	}
+	fromUnit, toUnit = strings.Trim(from, "\t") == "", strings.Trim(to, " ") == ""
+	if from != "" && to != "" && fromUnit && toUnit && len(to)%len(from) == 0 {
+		width := len(to) / len(from)
+		convert = func(indent string) string {
+			tabs := len(indent) - len(strings.TrimLeft(indent, "\t"))
+			return strings.Repeat(" ", tabs*width) + indent[tabs:]
+		}
+	}
+
+	result := []string{}
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			indent := indentation(line)
+			line = convert(indent) + line[len(indent):]
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
+func ambiguousEditError(lines []string, matches []int) error {
+	locations := []string{}
+	for _, index := range matches {
+		locations = append(locations, fmt.Sprintf("line %d: %s", index+1, strings.TrimSpace(lines[index])))
+	}
+	return fmt.Errorf("old_string matches %d places, add more context to make it unique:\n%s", len(matches), strings.Join(locations, "\n"))
+}
+
+// missingEditError lists the windows of the file sharing the most lines with
+// the snippet, so the model can correct it.
+func missingEditError(lines []string, target []string) error {
+	type candidate struct {
+		index int
+		score int
+	}
+
+	candidates := []candidate{}
+	for index := 0; index < len(lines); index++ {
+		score := 0
+		for i, targetLine := range target {
+			if index+i < len(lines) && strings.TrimSpace(lines[index+i]) == strings.TrimSpace(targetLine) {
+				score++
+			}
+		}
+		if score*2 >= len(target) && score > 0 {
+			candidates = append(candidates, candidate{index: index, score: score})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return errors.New("old_string was not found in the file")
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	if len(candidates) > 3 {
+		candidates = candidates[:3]
+	}
+
+	result := "old_string was not found in the file, closest matches:"
+	for _, c := range candidates {
+		end := min(c.index+len(target), len(lines))
+		result += fmt.Sprintf("\nlines %d-%d (%d of %d lines match):\n", c.index+1, end, c.score, len(target))
+		result += strings.Join(lines[c.index:end], "\n")
+	}
+	return errors.New(result)
+}
@@ -0,0 +1,153 @@
+package tool
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TZGyn/kode/internal/workspace"
+)
+
+func TestApplyEdit(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		edit    fileEdit
+		want    string
+	}{
+		{
+			name:    "exact",
+			content: "a\nb\nc\n",
+			edit:    fileEdit{oldString: "b\n", newString: "B\n"},
+			want:    "a\nB\nc\n",
+		},
+		{
+			name:    "exact within a line",
+			content: "x := compute(a, b)\n",
+			edit:    fileEdit{oldString: "compute(a, b)", newString: "compute(b, a)"},
+			want:    "x := compute(b, a)\n",
+		},
+		{
+			name:    "replace all",
+			content: "x\ny\nx\n",
+			edit:    fileEdit{oldString: "x", newString: "z", replaceAll: true},
+			want:    "z\ny\nz\n",
+		},
+		{
+			name:    "trailing whitespace",
+			content: "func f() {  \n\treturn 1\t\n}\r\n",
+			edit:    fileEdit{oldString: "func f() {\n\treturn 1\n}", newString: "func f() {\n\treturn 2\n}"},
+			want:    "func f() {\n\treturn 2\n}\n",
+		},
+		{
+			name:    "spaces to tabs",
+			content: "func f() {\n\tif x {\n\t\treturn 1\n\t}\n}\n",
+			edit:    fileEdit{oldString: "    if x {\n        return 1\n    }", newString: "    if x {\n        return 2\n    }"},
+			want:    "func f() {\n\tif x {\n\t\treturn 2\n\t}\n}\n",
+		},
+		{
+			name:    "tabs to spaces",
+			content: "def f():\n  if x:\n    return 1\n",
+			edit:    fileEdit{oldString: "\tif x:\n\t\treturn 1", newString: "\tif x:\n\t\tlog(x)\n\t\treturn 2"},
+			want:    "def f():\n  if x:\n    log(x)\n    return 2\n",
+		},
+		{
+			name:    "deeper indentation",
+			content: "func f() {\n\t\tx := 1\n\t\ty := 2\n}\n",
+			edit:    fileEdit{oldString: "x := 1\ny := 2", newString: "x := 1\nz := 3\ny := 2"},
+			want:    "func f() {\n\t\tx := 1\n\t\tz := 3\n\t\ty := 2\n}\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := applyEdit(test.content, test.edit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("applyEdit = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestApplyEditErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		edit    fileEdit
+		want    []string
+	}{
+		{
+			name:    "exact matches",
+			content: "x := 1\ny := 2\nx := 1\n",
+			edit:    fileEdit{oldString: "x := 1", newString: "x := 3"},
+			want:    []string{"matches 2 places", "line 1: x := 1", "line 3: x := 1"},
+		},
+		{
+			name:    "matches ignoring trailing whitespace",
+			content: "x := 1 \ny := 2\nx := 1\t\n",
+			edit:    fileEdit{oldString: "x := 1\n", newString: "x := 3\n"},
+			want:    []string{"matches 2 places", "line 1:", "line 3:"},
+		},
+		{
+			name:    "matches ignoring indentation",
+			content: "\tfoo()\n\tbar()\n\t\tfoo()\n\t\tbar()\n",
+			edit:    fileEdit{oldString: "    foo()\n    bar()", newString: "    baz()"},
+			want:    []string{"matches 2 places", "line 1: foo()", "line 3: foo()"},
+		},
+		{
+			name:    "near miss",
+			content: "package main\n\nfunc f() {\n\treturn 1\n}\n",
+			edit:    fileEdit{oldString: "func f() {\n\treturn 2\n}", newString: "func f() {\n\treturn 3\n}"},
+			want:    []string{"not found in the file, closest matches:", "lines 3-5 (2 of 3 lines match):\nfunc f() {\n\treturn 1\n}"},
+		},
+		{
+			name:    "not found",
+			content: "package main\n",
+			edit:    fileEdit{oldString: "func g() {}", newString: ""},
+			want:    []string{"old_string was not found in the file"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := applyEdit(test.content, test.edit)
+			if err == nil {
+				t.Fatal("applyEdit succeeded")
+			}
+			for _, want := range test.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error = %q, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+// TestEditFile applies the edits in order, and writes nothing when one fails.
+func TestEditFile(t *testing.T) {
+	ws := setupFiles(t, map[string]string{"a.txt": "one\ntwo\n"})
+	ctx := workspace.NewContext(t.Context(), ws)
+
+	edit := func(oldString, newString string) map[string]any {
+		return map[string]any{"old_string": oldString, "new_string": newString}
+	}
+
+	response := ""
+	result, _ := EditFileTool{}.Execute(ctx, map[string]any{"path": "a.txt", "edits": []any{edit("one", "1"), edit("1\ntwo", "1\n2")}}, &response)
+	if result != "File updated, 2 edits applied" {
+		t.Fatalf("Execute = %q", result)
+	}
+	if files := readFiles(t, ws); files["a.txt"] != "1\n2\n" {
+		t.Errorf("a.txt = %q, want both edits", files["a.txt"])
+	}
+
+	result, _ = EditFileTool{}.Execute(ctx, map[string]any{"path": "a.txt", "edits": []any{edit("1", "one"), edit("three", "3")}}, &response)
+	if !strings.HasPrefix(result, "edit 2 failed, no changes were written") {
+		t.Errorf("Execute = %q, want edit 2 to fail", result)
+	}
+	if files := readFiles(t, ws); files["a.txt"] != "1\n2\n" {
+		t.Errorf("a.txt = %q, want it untouched", files["a.txt"])
+	}
+}
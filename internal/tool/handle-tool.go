@@ -1,110 +1,81 @@
 package tool
 
 import (
+	"context"
 	"errors"
-	"os"
-	"strings"
 
+	"github.com/TZGyn/kode/internal/permission"
+	"github.com/TZGyn/kode/internal/workspace"
 	"github.com/aymanbagabas/go-udiff"
 )
 
-func HandleTool(toolName string, args map[string]any, response *string) (string, error) {
-	if toolName == "list_directory" {
-		result := []string{}
-
-		directory, ok := args["directory"].(string)
-		if ok {
-			entires, err := ListDirectory(directory)
-			if err == nil {
-				result = entires
-			}
-
-			toolResult := ""
-			toolResult += "## Files Start\n"
-			for _, entry := range result {
-				toolResult += "- " + entry + "\n"
-			}
-			toolResult += "## Files End\n"
-
-			*response = *response + toolResult
-		}
-		return strings.Join(result, "\n"), nil
+// HandleTool runs a tool call once the permission session carried by ctx
+// approves it. Rejections are returned as the tool result so the model can
+// adjust.
+func HandleTool(ctx context.Context, toolName string, args map[string]any, response *string) (string, error) {
+	tool, ok := Default.Get(toolName)
+	if !ok {
+		return "", errors.New("invalid tool")
 	}
-	if toolName == "cat_file" {
-		result := ""
 
-		filePath, ok := args["filePath"].(string)
-		if ok {
-			content, err := CatFile(filePath)
-			if err == nil {
-				result = content
+	request := permission.Request{
+		Tool:     toolName,
+		Args:     args,
+		ReadOnly: true,
+	}
+	if pathTool, ok := tool.(PathTool); ok {
+		ws := workspace.FromContext(ctx)
+		for _, path := range pathTool.Paths(args) {
+			resolved, err := ws.ResolveReadable(path)
+			if err != nil {
+				return err.Error(), err
 			}
-
-			toolResult := ""
-			toolResult += "## File content " + filePath + "\n"
-			toolResult += result + "\n"
-			toolResult += "## File content\n"
-
-			*response = *response + toolResult
+			request.Paths = append(request.Paths, ws.Rel(resolved))
 		}
-
-		return result, nil
 	}
-	if toolName == "create_file" {
-		result := ""
-		path, ok := args["filePath"].(string)
-		if ok {
-			err := CreateFile(path)
-			if err == nil {
-				result = "File Created Successfully"
-			} else {
-				result = err.Error()
-			}
-
-			toolResult := ""
-			toolResult += "## File create\n"
-			toolResult += path + "\n"
-			toolResult += "## File create\n"
-
-			*response = *response + toolResult
+	if mutatingTool, ok := tool.(MutatingTool); ok {
+		preview, err := mutatingTool.Preview(ctx, args)
+		if err != nil {
+			return err.Error(), err
 		}
-
-		return result, nil
+		request.ReadOnly = false
+		request.Preview = preview
 	}
 
-	if toolName == "update_file" {
-		result := ""
-		path, pathOk := args["path"].(string)
-		new_content, ok := args["new_content"].(string)
-		if ok && pathOk {
-			file, err := os.ReadFile("./" + path)
-			if err != nil {
-				result = err.Error()
-				return result, err
-			}
+	decision := permission.Check(ctx, request)
+	if !decision.Approved() {
+		toolResult := ""
+		toolResult += "## Tool rejected\n"
+		toolResult += toolName + "\n"
+		if decision.Feedback != "" {
+			toolResult += "> " + decision.Feedback + "\n"
+		}
+		toolResult += "## Tool rejected\n"
 
-			output, err := UpdateFile(path, new_content)
-			result = output
-			if err != nil {
-				result = err.Error()
-				return result, err
-			}
+		*response = *response + toolResult
 
-			edits := udiff.Strings(string(file), new_content)
+		return decision.Message(), nil
+	}
 
-			unified, _ := udiff.ToUnified("a/"+path, "b/"+path, string(file), edits, 8)
+	return tool.Execute(ctx, args, response)
+}
 
-			toolResult := ""
-			toolResult += "## File update\n"
-			toolResult += "```diff\n"
-			toolResult += unified + "\n"
-			toolResult += "```\n"
-			toolResult += "## File update\n"
+func fileDiff(from string, to string, before string, after string) string {
+	edits := udiff.Strings(before, after)
+	unified, _ := udiff.ToUnified(from, to, before, edits, 8)
+	return unified
+}
 
-			*response = *response + toolResult
-		}
-		return result, nil
+// fileUpdateOutput renders unified diffs as the "## File update" section shown
+// in the transcript.
+func fileUpdateOutput(diffs ...string) string {
+	toolResult := ""
+	toolResult += "## File update\n"
+	toolResult += "```diff\n"
+	for _, diff := range diffs {
+		toolResult += diff + "\n"
 	}
-
-	return "", errors.New("invalid tool")
+	toolResult += "```\n"
+	toolResult += "## File update\n"
+	return toolResult
 }
@@ -1,15 +1,74 @@
 package tool
 
 import (
+	"context"
 	"os"
 	"sort"
 	"strings"
+
+	"github.com/TZGyn/kode/internal/workspace"
 )
 
+type ListDirectoryTool struct{}
+
+func (ListDirectoryTool) Name() string {
+	return "list_directory"
+}
+
+func (ListDirectoryTool) Description() string {
+	return "Given a directory, return all the children of it"
+}
+
+func (ListDirectoryTool) Parameters() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"directory": {
+				Type:        "string",
+				Description: "the directory to output, use . for root",
+			},
+		},
+		Required: []string{"directory"},
+	}
+}
+
+func (ListDirectoryTool) Paths(args map[string]any) []string {
+	directory, _ := args["directory"].(string)
+	return []string{directory}
+}
+
+func (ListDirectoryTool) Execute(ctx context.Context, args map[string]any, response *string) (string, error) {
+	result := []string{}
+
+	directory, ok := args["directory"].(string)
+	if ok {
+		path, err := workspace.FromContext(ctx).ResolveReadable(directory)
+		if err != nil {
+			return err.Error(), err
+		}
+
+		entires, err := ListDirectory(path)
+		if err != nil {
+			return err.Error(), err
+		}
+		result = entires
+
+		toolResult := ""
+		toolResult += "## Files Start\n"
+		for _, entry := range result {
+			toolResult += "- " + entry + "\n"
+		}
+		toolResult += "## Files End\n"
+
+		*response = *response + toolResult
+	}
+	return strings.Join(result, "\n"), nil
+}
+
 func ListDirectory(directory string) ([]string, error) {
 	result := []string{}
 
-	entires, err := os.ReadDir("./" + directory)
+	entires, err := os.ReadDir(directory)
 	if err != nil {
 		return result, err
 	}
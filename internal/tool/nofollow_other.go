@@ -0,0 +1,9 @@
+//go:build !windows
+
+package tool
+
+import "syscall"
+
+// oNoFollow makes opening a file for writing fail on a symlink, the paths
+// resolved by the workspace being free of them.
+const oNoFollow = syscall.O_NOFOLLOW
@@ -0,0 +1,7 @@
+//go:build windows
+
+package tool
+
+// oNoFollow is unsupported on Windows, where the resolution of the workspace
+// is relied on.
+const oNoFollow = 0
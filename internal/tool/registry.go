@@ -0,0 +1,108 @@
+package tool
+
+import "context"
+
+// Schema is the JSON Schema subset used to describe tool arguments. Providers
+// convert it to their own declaration format.
+type Schema struct {
+	Type        string             `json:"type"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+}
+
+// Map returns the schema as a generic JSON object.
+func (s *Schema) Map() map[string]any {
+	result := map[string]any{
+		"type": s.Type,
+	}
+	if s.Description != "" {
+		result["description"] = s.Description
+	}
+	if len(s.Properties) > 0 {
+		properties := map[string]any{}
+		for name, property := range s.Properties {
+			properties[name] = property.Map()
+		}
+		result["properties"] = properties
+	}
+	if s.Items != nil {
+		result["items"] = s.Items.Map()
+	}
+	if len(s.Enum) > 0 {
+		result["enum"] = s.Enum
+	}
+	if len(s.Required) > 0 {
+		result["required"] = s.Required
+	}
+	return result
+}
+
+// Tool is a function the model can call. Execute returns the result sent back
+// to the model and appends a markdown rendering of the call to response. ctx is
+// cancelled when the turn is aborted.
+type Tool interface {
+	Name() string
+	Description() string
+	Parameters() *Schema
+	Execute(ctx context.Context, args map[string]any, response *string) (string, error)
+}
+
+// PathTool is implemented by tools working on files. Paths lists the files a
+// call touches, relative to the repository root.
+type PathTool interface {
+	Paths(args map[string]any) []string
+}
+
+// MutatingTool is implemented by tools changing the workspace. Preview renders
+// the change a call would make without applying it. Tools that do not
+// implement it are considered read-only.
+type MutatingTool interface {
+	Preview(ctx context.Context, args map[string]any) (string, error)
+}
+
+type Registry struct {
+	tools map[string]Tool
+	order []string
+}
+
+func NewRegistry(tools ...Tool) *Registry {
+	r := &Registry{tools: map[string]Tool{}}
+	for _, tool := range tools {
+		r.Register(tool)
+	}
+	return r
+}
+
+func (r *Registry) Register(tool Tool) {
+	if _, ok := r.tools[tool.Name()]; !ok {
+		r.order = append(r.order, tool.Name())
+	}
+	r.tools[tool.Name()] = tool
+}
+
+func (r *Registry) Get(name string) (Tool, bool) {
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// Tools returns the registered tools in registration order.
+func (r *Registry) Tools() []Tool {
+	tools := make([]Tool, 0, len(r.order))
+	for _, name := range r.order {
+		tools = append(tools, r.tools[name])
+	}
+	return tools
+}
+
+var Default = NewRegistry(
+	ListDirectoryTool{},
+	CatFileTool{},
+	CreateFileTool{},
+	UpdateFileTool{},
+	EditFileTool{},
+	ApplyPatchTool{},
+	RunCommandTool{},
+)
@@ -0,0 +1,166 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/TZGyn/kode/internal/workspace"
+)
+
+const (
+	defaultCommandTimeout = 2 * time.Minute
+	maxCommandTimeout     = 10 * time.Minute
+	maxCommandOutput      = 32 * 1024
+)
+
+type RunCommandTool struct{}
+
+func (RunCommandTool) Name() string {
+	return "run_command"
+}
+
+func (RunCommandTool) Description() string {
+	return "Run a shell command in the repository root and return its exit code and combined stdout and stderr. Use it to build, test or inspect the project. Commands cannot read from stdin"
+}
+
+func (RunCommandTool) Parameters() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"command": {
+				Type:        "string",
+				Description: "the shell command to run",
+			},
+			"timeout": {
+				Type:        "integer",
+				Description: "timeout in seconds, defaults to 120 and cannot exceed 600",
+			},
+		},
+		Required: []string{"command"},
+	}
+}
+
+func (RunCommandTool) Preview(ctx context.Context, args map[string]any) (string, error) {
+	command, ok := args["command"].(string)
+	if !ok || strings.TrimSpace(command) == "" {
+		return "", errors.New("missing command")
+	}
+	return "```\n$ " + command + "\n```\n", nil
+}
+
+func (RunCommandTool) Execute(ctx context.Context, args map[string]any, response *string) (string, error) {
+	command, ok := args["command"].(string)
+	if !ok || strings.TrimSpace(command) == "" {
+		return "missing command", errors.New("missing command")
+	}
+
+	timeout := defaultCommandTimeout
+	if seconds, ok := args["timeout"].(float64); ok && seconds > 0 {
+		timeout = min(time.Duration(seconds)*time.Second, maxCommandTimeout)
+	}
+
+	output, exitCode, err := RunCommand(ctx, workspace.FromContext(ctx).Root, command, timeout)
+
+	result := fmt.Sprintf("Exit code: %d\n", exitCode)
+	if err != nil {
+		result = err.Error() + "\n" + result
+	}
+	result += output
+
+	toolResult := ""
+	toolResult += "## Command\n"
+	toolResult += "```\n"
+	toolResult += "$ " + command + "\n"
+	toolResult += output
+	if !strings.HasSuffix(output, "\n") && output != "" {
+		toolResult += "\n"
+	}
+	toolResult += "```\n"
+	toolResult += fmt.Sprintf("Exit code: %d\n", exitCode)
+	toolResult += "## Command\n"
+
+	*response = *response + toolResult
+
+	if err == nil && exitCode != 0 {
+		err = fmt.Errorf("command exited with code %d", exitCode)
+	}
+	return result, err
+}
+
+// RunCommand runs command through the shell in dir. The whole process group
+// is killed when ctx is cancelled or the timeout expires. The exit code is -1
+// when the command could not be started or was killed.
+func RunCommand(ctx context.Context, dir string, command string, timeout time.Duration) (string, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, command)
+	cmd.Dir = dir
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+	cmd.WaitDelay = 5 * time.Second
+
+	output := &outputBuffer{limit: maxCommandOutput}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	err := cmd.Run()
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return output.String(), -1, fmt.Errorf("command timed out after %s", timeout)
+	}
+	if ctx.Err() != nil {
+		return output.String(), -1, errors.New("command cancelled")
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return output.String(), exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return output.String(), -1, err
+	}
+
+	return output.String(), 0, nil
+}
+
+// outputBuffer keeps the beginning and the end of the output, dropping the
+// middle once the limit is reached.
+type outputBuffer struct {
+	limit int
+	head  []byte
+	tail  []byte
+	total int
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.total += n
+
+	if room := b.limit/2 - len(b.head); room > 0 {
+		k := min(room, len(p))
+		b.head = append(b.head, p[:k]...)
+		p = p[k:]
+	}
+
+	b.tail = append(b.tail, p...)
+	if over := len(b.tail) - b.limit/2; over > 0 {
+		b.tail = append([]byte{}, b.tail[over:]...)
+	}
+
+	return n, nil
+}
+
+func (b *outputBuffer) String() string {
+	dropped := b.total - len(b.head) - len(b.tail)
+	if dropped == 0 {
+		return string(b.head) + string(b.tail)
+	}
+	return string(b.head) + fmt.Sprintf("\n... %d bytes truncated ...\n", dropped) + string(b.tail)
+}
@@ -0,0 +1,109 @@
+//go:build !windows
+
+package tool
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/TZGyn/kode/internal/workspace"
+)
+
+func TestRunCommandExitCode(t *testing.T) {
+	output, exitCode, err := RunCommand(t.Context(), t.TempDir(), "echo out; echo err >&2; exit 3", defaultCommandTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 3 || output != "out\nerr\n" {
+		t.Errorf("RunCommand = %q, %d, want both streams and exit code 3", output, exitCode)
+	}
+}
+
+func TestRunCommandTimeout(t *testing.T) {
+	start := time.Now()
+	// The background sleep keeps the output open, it is only closed once the
+	// whole process group is killed.
+	output, exitCode, err := RunCommand(t.Context(), t.TempDir(), "echo started; sleep 30 & wait", 200*time.Millisecond)
+
+	if err == nil || err.Error() != "command timed out after 200ms" {
+		t.Errorf("RunCommand error = %v, want a timeout", err)
+	}
+	if exitCode != -1 || output != "started\n" {
+		t.Errorf("RunCommand = %q, %d, want the output so far and -1", output, exitCode)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("RunCommand returned after %s", elapsed)
+	}
+}
+
+// TestRunCommandCancel cancels a command whose child runs a grandchild, every
+// one of them must be killed.
+func TestRunCommandCancel(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := RunCommand(ctx, dir, `sh -c 'echo $$ > grandchild.pid; sleep 30' & wait`, defaultCommandTimeout)
+		done <- err
+	}()
+
+	pid := 0
+	for deadline := time.Now().Add(5 * time.Second); pid == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("grandchild did not start")
+		}
+		content, err := os.ReadFile(filepath.Join(dir, "grandchild.pid"))
+		if err == nil && strings.HasSuffix(string(content), "\n") {
+			pid, _ = strconv.Atoi(strings.TrimSpace(string(content)))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "command cancelled" {
+			t.Errorf("RunCommand error = %v, want it cancelled", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("RunCommand did not return once cancelled")
+	}
+
+	for deadline := time.Now().Add(3 * time.Second); ; {
+		if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("grandchild %d is still running", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestRunCommandToolFailure fails the call of a command exiting non-zero, the
+// result keeping its output for the model.
+func TestRunCommandToolFailure(t *testing.T) {
+	ctx := workspace.NewContext(t.Context(), setupFiles(t, nil))
+
+	response := ""
+	result, err := RunCommandTool{}.Execute(ctx, map[string]any{"command": "echo boom; exit 2"}, &response)
+	if err == nil || err.Error() != "command exited with code 2" {
+		t.Errorf("Execute error = %v, want the exit code", err)
+	}
+	if result != "Exit code: 2\nboom\n" {
+		t.Errorf("Execute = %q, want the exit code and output", result)
+	}
+
+	if _, err := (RunCommandTool{}).Execute(ctx, map[string]any{"command": "true"}, &response); err != nil {
+		t.Errorf("Execute of a successful command: %v", err)
+	}
+}
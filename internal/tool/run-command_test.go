@@ -0,0 +1,42 @@
+package tool
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOutputBuffer(t *testing.T) {
+	small := &outputBuffer{limit: 16}
+	small.Write([]byte("hello "))
+	small.Write([]byte("world"))
+	if got := small.String(); got != "hello world" {
+		t.Errorf("String() = %q, want the output untouched", got)
+	}
+
+	b := &outputBuffer{limit: 16}
+	for _, chunk := range []string{"0123", "4567", "89ab", "cdef", "ghij", "klmn", "opqr"} {
+		b.Write([]byte(chunk))
+	}
+	if got, want := b.String(), "01234567\n... 12 bytes truncated ...\nklmnopqr"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestRunCommandTruncated(t *testing.T) {
+	// 100000 bytes of "x\n".
+	output, exitCode, err := RunCommand(t.Context(), t.TempDir(), "yes x | head -n 50000", defaultCommandTimeout)
+	if err != nil || exitCode != 0 {
+		t.Fatalf("RunCommand = %d, %v", exitCode, err)
+	}
+
+	head, tail, found := strings.Cut(output, "\n... 67232 bytes truncated ...\n")
+	if !found {
+		t.Fatalf("output of %d bytes has no truncation marker", len(output))
+	}
+	if len(head) != maxCommandOutput/2 || len(tail) != maxCommandOutput/2 {
+		t.Errorf("kept %d and %d bytes, want %d of each end", len(head), len(tail), maxCommandOutput/2)
+	}
+	if strings.Trim(head+tail, "x\n") != "" {
+		t.Errorf("kept output is not the command's")
+	}
+}
@@ -1,12 +1,107 @@
 package tool
 
-import "os"
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/TZGyn/kode/internal/workspace"
+)
+
+type UpdateFileTool struct{}
+
+func (UpdateFileTool) Name() string {
+	return "update_file"
+}
+
+func (UpdateFileTool) Description() string {
+	return "Update file, given the complete new file content"
+}
+
+func (UpdateFileTool) Parameters() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"path": {
+				Type:        "string",
+				Description: "Path of the file",
+			},
+			"new_content": {
+				Type:        "string",
+				Description: "New file content",
+			},
+		},
+		Required: []string{"path", "new_content"},
+	}
+}
+
+func (UpdateFileTool) Paths(args map[string]any) []string {
+	path, _ := args["path"].(string)
+	return []string{path}
+}
+
+func (UpdateFileTool) Preview(ctx context.Context, args map[string]any) (string, error) {
+	path, pathOk := args["path"].(string)
+	new_content, ok := args["new_content"].(string)
+	if !ok || !pathOk {
+		return "", errors.New("missing path or new_content")
+	}
+
+	resolved, err := workspace.FromContext(ctx).Resolve(path)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.ReadFile(resolved)
+	if err != nil {
+		return "", err
+	}
+
+	return fileUpdateOutput(fileDiff("a/"+path, "b/"+path, string(file), new_content)), nil
+}
+
+func (UpdateFileTool) Execute(ctx context.Context, args map[string]any, response *string) (string, error) {
+	result := ""
+	path, pathOk := args["path"].(string)
+	new_content, ok := args["new_content"].(string)
+	if ok && pathOk {
+		resolved, err := workspace.FromContext(ctx).Resolve(path)
+		if err != nil {
+			return err.Error(), err
+		}
+
+		file, err := os.ReadFile(resolved)
+		if err != nil {
+			result = err.Error()
+			return result, err
+		}
+
+		output, err := UpdateFile(resolved, new_content)
+		result = output
+		if err != nil {
+			result = err.Error()
+			return result, err
+		}
+
+		unified := fileDiff("a/"+path, "b/"+path, string(file), new_content)
+
+		*response = *response + fileUpdateOutput(unified)
+	}
+	return result, nil
+}
 
 func UpdateFile(path string, new_content string) (string, error) {
-	err := os.WriteFile("./"+path, []byte(new_content), 0644)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC|oNoFollow, 0644)
 	if err != nil {
 		return "", err
 	}
+	if _, err := f.WriteString(new_content); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
 
 	return "File updated", nil
 }
@@ -0,0 +1,54 @@
+package tool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateFile(t *testing.T) {
+	ws := setupFiles(t, map[string]string{"a.txt": "a long first version\n"})
+
+	if _, err := UpdateFile(filepath.Join(ws.Root, "a.txt"), "short\n"); err != nil {
+		t.Fatal(err)
+	}
+	if files := readFiles(t, ws); files["a.txt"] != "short\n" {
+		t.Errorf("a.txt = %q, want it replaced", files["a.txt"])
+	}
+
+	if _, err := UpdateFile(filepath.Join(ws.Root, "missing.txt"), "new\n"); err == nil {
+		t.Error("UpdateFile created a missing file")
+	}
+}
+
+// TestUpdateFileSymlink swaps the resolved file for a symlink leading out of
+// the workspace, the write must not follow it.
+func TestUpdateFileSymlink(t *testing.T) {
+	if oNoFollow == 0 {
+		t.Skip("symlinks are not refused on this platform")
+	}
+
+	ws := setupFiles(t, map[string]string{"a.txt": "a\n"})
+	outside := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(outside, []byte("secret\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, err := ws.Resolve("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(resolved); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, resolved); err != nil {
+		t.Skip("symlinks unsupported:", err)
+	}
+
+	if _, err := UpdateFile(resolved, "overwritten\n"); err == nil {
+		t.Error("UpdateFile wrote through a symlink")
+	}
+	if content, _ := os.ReadFile(outside); string(content) != "secret\n" {
+		t.Errorf("file outside of the workspace = %q, want it untouched", content)
+	}
+}
@@ -0,0 +1,193 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Workspace confines file access to a root directory. Paths are resolved
+// relative to the root, symlinks included, and rejected when they end up
+// outside of it. Readable directories are additionally allowed for reads.
+type Workspace struct {
+	Root     string
+	readable []string
+}
+
+var ErrOutside = errors.New("path is outside of the workspace")
+
+func New(root string, readable ...string) (*Workspace, error) {
+	root, err := canonical(root)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Workspace{Root: root}
+	for _, directory := range readable {
+		directory, err := canonical(expandHome(directory))
+		if err != nil {
+			return nil, fmt.Errorf("readable directory: %w", err)
+		}
+		w.readable = append(w.readable, directory)
+	}
+
+	return w, nil
+}
+
+// Detect returns the workspace of the git repository containing the current
+// directory.
+func Detect(readable ...string) (*Workspace, error) {
+	stdout, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, errors.New("invalid git repo")
+	}
+
+	return New(strings.TrimSpace(string(stdout)), readable...)
+}
+
+// Resolve returns the absolute path of path, which may be written to.
+func (w *Workspace) Resolve(path string) (string, error) {
+	resolved, err := w.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	if !within(w.Root, resolved) {
+		return "", fmt.Errorf("%s: %w", path, ErrOutside)
+	}
+	return resolved, nil
+}
+
+// ResolveReadable is like Resolve but also accepts the readable directories.
+func (w *Workspace) ResolveReadable(path string) (string, error) {
+	resolved, err := w.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	if within(w.Root, resolved) {
+		return resolved, nil
+	}
+	for _, directory := range w.readable {
+		if within(directory, resolved) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("%s: %w", path, ErrOutside)
+}
+
+// Rel returns a resolved path relative to the root, using forward slashes.
+func (w *Workspace) Rel(path string) string {
+	rel, err := filepath.Rel(w.Root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (w *Workspace) resolve(path string) (string, error) {
+	if path == "" {
+		path = "."
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(w.Root, path)
+	}
+	return canonical(path)
+}
+
+// maxLinks bounds the dangling symlinks followed by canonical, against loops.
+const maxLinks = 255
+
+// canonical cleans path and resolves the symlinks of its longest existing
+// prefix, so paths of files that are about to be created resolve too. A
+// dangling symlink is resolved to its target, which is where writing to it
+// would create the file.
+func canonical(path string) (string, error) {
+	return canonicalLinks(path, 0)
+}
+
+func canonicalLinks(path string, links int) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	missing := []string{}
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, missing[i])
+			}
+			return resolved, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+
+		if info, lerr := os.Lstat(path); lerr == nil && info.Mode()&os.ModeSymlink != 0 {
+			if links == maxLinks {
+				return "", fmt.Errorf("%s: too many levels of symbolic links", path)
+			}
+			target, err := os.Readlink(path)
+			if err != nil {
+				return "", err
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(path), target)
+			}
+			for i := len(missing) - 1; i >= 0; i-- {
+				target = filepath.Join(target, missing[i])
+			}
+			return canonicalLinks(target, links+1)
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		missing = append(missing, filepath.Base(path))
+		path = parent
+	}
+}
+
+func within(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, w *Workspace) context.Context {
+	return context.WithValue(ctx, contextKey{}, w)
+}
+
+// FromContext returns the workspace carried by ctx, falling back to one rooted
+// at the current directory.
+func FromContext(ctx context.Context) *Workspace {
+	if w, ok := ctx.Value(contextKey{}).(*Workspace); ok && w != nil {
+		return w
+	}
+
+	w, err := New(".")
+	if err != nil {
+		return &Workspace{Root: "."}
+	}
+	return w
+}
@@ -0,0 +1,156 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setup returns a workspace rooted in a temporary directory, along with a
+// sibling directory outside of it.
+func setup(t *testing.T) (*Workspace, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	outside := filepath.Join(dir, "outside")
+	for _, directory := range []string{root, outside} {
+		if err := os.Mkdir(directory, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, outside
+}
+
+func symlink(t *testing.T, target string, link string) {
+	t.Helper()
+
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip("symlinks unsupported:", err)
+	}
+}
+
+func TestResolveInside(t *testing.T) {
+	w, _ := setup(t)
+
+	for _, path := range []string{"", ".", "a.go", "dir/new.go", "dir/../b.go", filepath.Join(w.Root, "c.go")} {
+		resolved, err := w.Resolve(path)
+		if err != nil {
+			t.Errorf("Resolve(%q): %v", path, err)
+			continue
+		}
+		if !within(w.Root, resolved) {
+			t.Errorf("Resolve(%q) = %s, outside of %s", path, resolved, w.Root)
+		}
+	}
+}
+
+func TestResolveTraversal(t *testing.T) {
+	w, outside := setup(t)
+
+	for _, path := range []string{"..", "../outside/a", "dir/../../outside", "a/../../../etc/passwd"} {
+		if _, err := w.Resolve(path); !errors.Is(err, ErrOutside) {
+			t.Errorf("Resolve(%q) = %v, want ErrOutside", path, err)
+		}
+	}
+
+	if _, err := w.Resolve(filepath.Join(outside, "a")); !errors.Is(err, ErrOutside) {
+		t.Errorf("Resolve of an absolute path outside = %v, want ErrOutside", err)
+	}
+}
+
+func TestResolveSymlinks(t *testing.T) {
+	w, outside := setup(t)
+
+	if err := os.WriteFile(filepath.Join(outside, "secret"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(w.Root, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	symlink(t, filepath.Join(outside, "secret"), filepath.Join(w.Root, "file-link"))
+	symlink(t, outside, filepath.Join(w.Root, "dir-link"))
+	symlink(t, "file", filepath.Join(w.Root, "inside-link"))
+
+	for _, path := range []string{"file-link", "dir-link", "dir-link/secret", "dir-link/new"} {
+		if _, err := w.Resolve(path); !errors.Is(err, ErrOutside) {
+			t.Errorf("Resolve(%q) = %v, want ErrOutside", path, err)
+		}
+	}
+
+	resolved, err := w.Resolve("inside-link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved != filepath.Join(w.Root, "file") {
+		t.Errorf("Resolve(inside-link) = %s, want the file it links to", resolved)
+	}
+}
+
+func TestResolveDanglingSymlinks(t *testing.T) {
+	w, outside := setup(t)
+
+	// Writing to these links would create files outside of the root.
+	symlink(t, filepath.Join(outside, "missing"), filepath.Join(w.Root, "a"))
+	symlink(t, "../outside/missing", filepath.Join(w.Root, "b"))
+	symlink(t, filepath.Join(outside, "missing-dir"), filepath.Join(w.Root, "c"))
+	symlink(t, "b", filepath.Join(w.Root, "chained"))
+
+	for _, path := range []string{"a", "b", "c/new", "chained"} {
+		if _, err := w.Resolve(path); !errors.Is(err, ErrOutside) {
+			t.Errorf("Resolve(%q) = %v, want ErrOutside", path, err)
+		}
+	}
+
+	symlink(t, "missing", filepath.Join(w.Root, "d"))
+	resolved, err := w.Resolve("d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved != filepath.Join(w.Root, "missing") {
+		t.Errorf("Resolve(d) = %s, want the target inside the root", resolved)
+	}
+}
+
+func TestResolveSymlinkLoop(t *testing.T) {
+	w, _ := setup(t)
+
+	symlink(t, "loop-b", filepath.Join(w.Root, "loop-a"))
+	symlink(t, "loop-a", filepath.Join(w.Root, "loop-b"))
+
+	if _, err := w.Resolve("loop-a"); err == nil {
+		t.Error("Resolve of a symlink loop succeeded")
+	}
+}
+
+func TestResolveReadable(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	docs := filepath.Join(dir, "docs")
+	for _, directory := range []string{root, docs} {
+		if err := os.Mkdir(directory, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := New(root, docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.ResolveReadable(filepath.Join(docs, "guide.md")); err != nil {
+		t.Errorf("ResolveReadable in a readable directory: %v", err)
+	}
+	if _, err := w.Resolve(filepath.Join(docs, "guide.md")); !errors.Is(err, ErrOutside) {
+		t.Errorf("Resolve in a readable directory = %v, want ErrOutside", err)
+	}
+	if _, err := w.ResolveReadable(filepath.Join(dir, "other")); !errors.Is(err, ErrOutside) {
+		t.Errorf("ResolveReadable outside = %v, want ErrOutside", err)
+	}
+}